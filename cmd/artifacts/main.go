@@ -0,0 +1,120 @@
+package artifacts
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Session keeps track of the files a day's run leaves behind. Every file is named after the
+// day, part and input so runs of different puzzles never write over each other.
+type Session struct {
+	Dir   string
+	Day   string
+	Part  int
+	Input string
+
+	produced  []string
+	temporary []string
+}
+
+// current is the run in progress, days write into it without having to pass it around.
+var current *Session
+
+// Start opens a session for the command, the directory is only made once something is written.
+func Start(cmd *cobra.Command) {
+	dir, _ := cmd.Flags().GetString("artifacts-dir")
+	s := &Session{Dir: dir, Day: cmd.Name(), Part: 1}
+	if f := cmd.Flag("part-two"); f != nil && f.Changed {
+		s.Part = 2
+	}
+	s.Input, _ = cmd.Flags().GetString("puzzle-input")
+	current = s
+}
+
+// prefix is what every file of the session starts with, like dayTen-part2-sample.
+func (s *Session) prefix() string {
+	input := filepath.Base(s.Input)
+	input = strings.TrimSuffix(input, filepath.Ext(input))
+	if input == "" || input == "." {
+		input = "noinput"
+	}
+	return fmt.Sprintf("%s-part%d-%s", s.Day, s.Part, input)
+}
+
+// Path is where the named artifact goes, outside of a session it is just the name.
+func Path(name string) string {
+	if current == nil {
+		return name
+	}
+	return filepath.Join(current.Dir, current.prefix()+"-"+name)
+}
+
+// Write saves a debug artifact. Failing to is only worth a warning, it returns the path
+// written to or nothing when it couldn't.
+func Write(name string, data []byte) string {
+	path := Path(name)
+	if current != nil {
+		if err := os.MkdirAll(current.Dir, 0755); err != nil {
+			slog.Warn("failed to make the artifacts directory", "dir", current.Dir, "err", err)
+			return ""
+		}
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		slog.Warn("failed to write artifact", "path", path, "err", err)
+		return ""
+	}
+	Produced(path)
+	return path
+}
+
+// Produced notes a file the run made somewhere else, like a rendering, so it is listed too.
+func Produced(path string) {
+	if current == nil || slices.Contains(current.produced, path) {
+		return
+	}
+	current.produced = append(current.produced, path)
+}
+
+// Temp creates a scratch file in the artifacts directory that is removed when the run
+// finishes, pattern works like it does for os.CreateTemp.
+func Temp(pattern string) (*os.File, error) {
+	if current == nil {
+		return os.CreateTemp("", pattern)
+	}
+	if err := os.MkdirAll(current.Dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(current.Dir, current.prefix()+"-"+pattern)
+	if err != nil {
+		return nil, err
+	}
+	current.temporary = append(current.temporary, f.Name())
+	return f, nil
+}
+
+// Finish removes the temporary files and returns everything else the run produced.
+func Finish() []string {
+	if current == nil {
+		return nil
+	}
+	s := current
+	current = nil
+	for _, path := range s.temporary {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			slog.Warn("failed to clean up", "path", path, "err", err)
+		}
+	}
+	return s.produced
+}
+
+// AddFlags adds the artifacts flag, it is persistent so every day has it.
+func AddFlags(flags *pflag.FlagSet) {
+	flags.String("artifacts-dir", filepath.Join(os.TempDir(), "adventofcode"), "Directory debug files are written to")
+}
@@ -1,13 +1,15 @@
 package dayEight
 
 import (
+	"adventofcode/cmd/graph"
+	"adventofcode/cmd/util"
 	"bufio"
-	"encoding/json"
 	"fmt"
 	"log"
 	"log/slog"
+	"math/big"
 	"os"
-	"time"
+	"slices"
 
 	"github.com/spf13/cobra"
 )
@@ -53,11 +55,45 @@ func parse(path string) (string, map[string]*Node) {
 	return instructions, nodes
 }
 
-func partOne(puzzleFile string) {
+// Graph shows the network with start nodes as circles and end nodes as double circles, the
+// walk given is highlighted.
+func Graph(nodes map[string]*Node, walk []string) *graph.Graph {
+	names := []string{}
+	for name := range nodes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	g := graph.New("network", true)
+	for _, name := range names {
+		shape := graph.Rounded
+		switch name[2] {
+		case 'A':
+			shape = graph.Circle
+		case 'Z':
+			shape = graph.DoubleCircle
+		}
+		g.AddNode(name, name, shape)
+	}
+	for _, name := range names {
+		n := nodes[name]
+		if n.Left == n.Right {
+			g.AddEdge(name, n.Left, "L/R")
+			continue
+		}
+		g.AddEdge(name, n.Left, "L")
+		g.AddEdge(name, n.Right, "R")
+	}
+	g.HighlightPath(walk...)
+	return g
+}
+
+func partOne(puzzleFile string, export *graph.Request) {
 	instructions, nodes := parse(puzzleFile)
 	slog.Debug("parsed input", "instructions", instructions, "nodes", nodes)
 
 	cur := nodes["AAA"]
+	walk := []string{cur.Name}
 	steps := 0
 	for cur.Name != "ZZZ" {
 		switch instructions[steps%len(instructions)] {
@@ -67,89 +103,212 @@ func partOne(puzzleFile string) {
 			cur = nodes[cur.Right]
 		}
 		steps++
+		walk = append(walk, cur.Name)
 		slog.Debug("stepping", "cur", cur, "steps", steps)
 	}
+	if export != nil {
+		if err := export.Write(Graph(nodes, walk)); err != nil {
+			log.Fatal(err)
+		}
+	}
 	slog.Info("Day eight part one", "steps", steps)
 }
 
-func partTwo(puzzleFile string) {
-	instructions, nodes := parse(puzzleFile)
-	slog.Debug("parsed input", "input", instructions, "nodes", nodes)
+// State is where a ghost is and which instruction it follows next. Once a state comes
+// around again the ghost is stuck in a cycle.
+type State struct {
+	Instruction int
+	Node        string
+}
 
-	curs := map[string]*Node{}
-	zsVisited := map[string]map[string][]int{}
-	for _, node := range nodes {
-		if node.Name[2] == 'A' {
-			curs[node.Name] = node
-			zsVisited[node.Name] = map[string][]int{}
+// Ghost records a ghost's walk: the steps it takes before settling into a cycle, the
+// cycle's length and every step at which it stands on a Z node.
+type Ghost struct {
+	Start       string
+	CycleState  State
+	CycleStart  int
+	CycleLength int
+	// PrefixHits are Z steps before the cycle starts
+	PrefixHits []int
+	// CycleHits are Z steps within the first lap of the cycle
+	CycleHits []int
+}
+
+func (g *Ghost) String() string {
+	return fmt.Sprintf(
+		"Ghost{%s, cycle at %v from step %d, length %d, prefix hits %v, cycle hits %v}",
+		g.Start, g.CycleState, g.CycleStart, g.CycleLength, g.PrefixHits, g.CycleHits,
+	)
+}
+
+func trace(start string, instructions string, nodes map[string]*Node) *Ghost {
+	g := &Ghost{Start: start}
+	seen := map[State]int{}
+	hits := []int{}
+	cur := nodes[start]
+	for steps := 0; ; steps++ {
+		state := State{steps % len(instructions), cur.Name}
+		if first, ok := seen[state]; ok {
+			g.CycleState = state
+			g.CycleStart = first
+			g.CycleLength = steps - first
+			break
+		}
+		seen[state] = steps
+		if cur.Name[2] == 'Z' {
+			hits = append(hits, steps)
+		}
+
+		switch instructions[state.Instruction] {
+		case 'L':
+			cur = nodes[cur.Left]
+		case 'R':
+			cur = nodes[cur.Right]
 		}
 	}
-	slog.Info("initial curs", "curs", curs)
 
-	steps := 0
-	for {
-		i := instructions[steps%len(instructions)]
-		for origin, cur := range curs {
-			switch i {
-			case 'L':
-				cur = nodes[cur.Left]
-			case 'R':
-				cur = nodes[cur.Right]
+	for _, h := range hits {
+		if h < g.CycleStart {
+			g.PrefixHits = append(g.PrefixHits, h)
+		} else {
+			g.CycleHits = append(g.CycleHits, h)
+		}
+	}
+	return g
+}
+
+// OnZ reports whether the ghost stands on a Z node after the given number of steps.
+func (g *Ghost) OnZ(steps int) bool {
+	if steps < g.CycleStart {
+		return slices.Contains(g.PrefixHits, steps)
+	}
+	return slices.Contains(g.CycleHits, g.CycleStart+(steps-g.CycleStart)%g.CycleLength)
+}
+
+// crt merges x ≡ a1 (mod m1) with x ≡ a2 (mod m2), moduli needn't be coprime. It returns
+// false when the two can never agree.
+func crt(a1, m1, a2, m2 *big.Int) (*big.Int, *big.Int, bool) {
+	gcd := new(big.Int).GCD(nil, nil, m1, m2)
+	diff := new(big.Int).Sub(a2, a1)
+	if new(big.Int).Mod(diff, gcd).Sign() != 0 {
+		return nil, nil, false
+	}
+
+	m1g := new(big.Int).Quo(m1, gcd)
+	m2g := new(big.Int).Quo(m2, gcd)
+	lcm := new(big.Int).Mul(m1g, m2)
+
+	// a1 + m1*k ≡ a2 (mod m2) means k ≡ (diff/gcd) * (m1/gcd)^-1 (mod m2/gcd)
+	k := new(big.Int).Quo(diff, gcd)
+	if m2g.Cmp(big.NewInt(1)) == 0 {
+		k.SetInt64(0)
+	} else {
+		k.Mul(k, new(big.Int).ModInverse(m1g, m2g))
+		k.Mod(k, m2g)
+	}
+
+	x := new(big.Int).Mul(m1, k)
+	x.Add(x, a1)
+	return x.Mod(x, lcm), lcm, true
+}
+
+// syncSteps finds the fewest steps after which every ghost is on a Z node. Steps before
+// the last ghost enters its cycle are checked directly, after that each ghost pins the
+// step count to one of its cycle hits modulo its cycle length and we combine them all.
+func syncSteps(ghosts []*Ghost) (*big.Int, bool) {
+	settled := 0
+	for _, g := range ghosts {
+		settled = util.Max(settled, g.CycleStart)
+	}
+
+	// Early on, any answer has to be one of the first ghost's hits
+	first := ghosts[0]
+	for steps := 0; steps < settled; steps++ {
+		if !first.OnZ(steps) {
+			continue
+		}
+		allOnZ := true
+		for _, g := range ghosts[1:] {
+			if !g.OnZ(steps) {
+				allOnZ = false
+				break
 			}
-			curs[origin] = cur
 		}
-		steps++
+		if allOnZ {
+			return big.NewInt(int64(steps)), true
+		}
+	}
 
-		numZs := 0
-		for origin, cur := range curs {
-			if cur.Name[2] == 'Z' {
-				numZs++
-				if _, ok := zsVisited[origin][cur.Name]; !ok {
-					zsVisited[origin][cur.Name] = []int{}
-				}
-				zsVisited[origin][cur.Name] = append(zsVisited[origin][cur.Name], steps)
+	var best *big.Int
+	var combine func(i int, a, m *big.Int)
+	combine = func(i int, a, m *big.Int) {
+		if i == len(ghosts) {
+			// lift the solution until every ghost is in its cycle
+			steps := new(big.Int).Set(a)
+			if lag := new(big.Int).Sub(big.NewInt(int64(settled)), steps); lag.Sign() > 0 {
+				laps := new(big.Int).Add(lag, new(big.Int).Sub(m, big.NewInt(1)))
+				laps.Quo(laps, m)
+				steps.Add(steps, laps.Mul(laps, m))
+			}
+			if best == nil || steps.Cmp(best) < 0 {
+				best = steps
 			}
+			return
 		}
-		slog.Debug("stepping", "curs", curs, "steps", steps, "numZs", numZs)
-		/**if numZs > 1 {
-			slog.Info("stepping on zs", "curs", curs, "steps", steps, "numZs", numZs)
-		}**/
 
-		if numZs == len(curs) || allCycled(zsVisited) {
-			break
+		g := ghosts[i]
+		length := big.NewInt(int64(g.CycleLength))
+		for _, h := range g.CycleHits {
+			merged, lcm, ok := crt(a, m, big.NewInt(int64(h)), length)
+			if ok {
+				combine(i+1, merged, lcm)
+			}
 		}
 	}
-	j, _ := json.MarshalIndent(zsVisited, "", "  ")
-	os.WriteFile(fmt.Sprintf("inputs/zsVisited%d.json", time.Now().Nanosecond()), j, 0644)
+	combine(0, big.NewInt(0), big.NewInt(1))
 
-	slog.Info("Day eight part two", "steps", steps, "curs", curs)
+	return best, best != nil
 }
 
-// Turns out I had to look up the answer on Reddit. Once we're in a cycle, we can find the LCM of
-// the cycle lengths to find the number of steps to get every ghost in sync.
-func allCycled(zsVisited map[string]map[string][]int) bool {
-	for _, zs := range zsVisited {
-		hasCycle := false
-		for _, visits := range zs {
-			if len(visits) > 1 {
-				hasCycle = true
-			}
+func partTwo(puzzleFile string, export *graph.Request) {
+	instructions, nodes := parse(puzzleFile)
+	slog.Debug("parsed input", "input", instructions, "nodes", nodes)
+	if export != nil {
+		if err := export.Write(Graph(nodes, nil)); err != nil {
+			log.Fatal(err)
 		}
-		if !hasCycle {
-			return false
+	}
+
+	ghosts := []*Ghost{}
+	for _, node := range nodes {
+		if node.Name[2] == 'A' {
+			g := trace(node.Name, instructions, nodes)
+			slog.Debug("traced ghost", "ghost", g)
+			ghosts = append(ghosts, g)
 		}
 	}
-	return true
+	slog.Info("traced ghosts", "ghosts", len(ghosts))
+
+	steps, ok := syncSteps(ghosts)
+	if !ok {
+		log.Fatal("the ghosts never all stand on Z nodes together")
+	}
+
+	slog.Info("Day eight part two", "steps", steps)
 }
 
 var Cmd = &cobra.Command{
 	Use: "dayEight",
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
+		export, err := graph.FromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
 		if !cmd.Flag("part-two").Changed {
-			partOne(puzzleInput)
+			partOne(puzzleInput, export)
 		} else {
-			partTwo(puzzleInput)
+			partTwo(puzzleInput, export)
 		}
 	},
 }
@@ -1,11 +1,14 @@
 package dayEighteen
 
 import (
+	"adventofcode/cmd/artifacts"
 	"adventofcode/cmd/fileReader"
+	"adventofcode/cmd/render"
+	"adventofcode/cmd/util"
+	"errors"
 	"fmt"
+	"log"
 	"log/slog"
-	"math"
-	"os"
 	"strconv"
 	"strings"
 
@@ -15,8 +18,8 @@ import (
 )
 
 type Coordinate struct {
-	Row int
-	Col int
+	Row int64
+	Col int64
 }
 
 func (c *Coordinate) String() string {
@@ -29,7 +32,7 @@ func (c *Coordinate) Equals(other *Coordinate) bool {
 
 type DigCommand struct {
 	Dir   string `@Direction`
-	Dist  int    `@Int`
+	Dist  int64  `@Int`
 	Color string `@Color`
 }
 
@@ -37,9 +40,21 @@ func (d DigCommand) String() string {
 	return fmt.Sprintf("%s %d #%s", d.Dir, d.Dist, d.Color)
 }
 
-type Space struct {
-	Shape string
-	Color string
+// colorDirections are indexed by the last hex digit of the colour.
+var colorDirections = []string{"R", "D", "L", "U"}
+
+// Decode reads the real instruction out of the colour: the first five hex digits are the
+// distance and the last one the direction.
+func (d *DigCommand) Decode() (*DigCommand, error) {
+	dist, err := strconv.ParseInt(d.Color[:5], 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("decoding distance of %s: %w", d, err)
+	}
+	dir := int(d.Color[5] - '0')
+	if dir < 0 || dir >= len(colorDirections) {
+		return nil, fmt.Errorf("decoding direction of %s: unknown direction %c", d, d.Color[5])
+	}
+	return &DigCommand{colorDirections[dir], dist, d.Color}, nil
 }
 
 func ParseCommands(puzzleFile string) []*DigCommand {
@@ -72,163 +87,233 @@ func ParseCommands(puzzleFile string) []*DigCommand {
 	return commands
 }
 
+var ErrOverflow = errors.New("lagoon size overflows int64")
+
 type Map struct {
-	MaxX            int
-	MinX            int
-	MaxY            int
-	MinY            int
+	MaxX            int64
+	MinX            int64
+	MaxY            int64
+	MinY            int64
 	VerticesOrdered []*Coordinate
-	VerticesMapped  map[string]bool
 }
 
 func (m *Map) AddVertex(c *Coordinate) {
 	m.VerticesOrdered = append(m.VerticesOrdered, c)
-	m.VerticesMapped[c.String()] = true
 
-	if c.Col > m.MaxX {
-		m.MaxX = c.Col
-	}
-	if c.Col < m.MinX {
-		m.MinX = c.Col
-	}
-	if c.Row > m.MaxY {
-		m.MaxY = c.Row
-	}
-	if c.Row < m.MinY {
-		m.MinY = c.Row
-	}
+	m.MaxX = max(m.MaxX, c.Col)
+	m.MinX = min(m.MinX, c.Col)
+	m.MaxY = max(m.MaxY, c.Row)
+	m.MinY = min(m.MinY, c.Row)
 }
 
-func BuildMap(commands []*DigCommand) *Map {
-	maxX, maxY, minX, minY := 0, 0, 0, 0
-	theMap := &Map{maxX, minX, maxY, minY, []*Coordinate{}, map[string]bool{}}
-	pos := &Coordinate{0, 0}
+func BuildMap(commands []*DigCommand) (*Map, error) {
+	theMap := &Map{}
+	pos := Coordinate{0, 0}
 	for _, c := range commands {
+		var ok bool
 		switch c.Dir {
 		case "R":
-			pos.Col += c.Dist
+			pos.Col, ok = util.CheckedAdd(pos.Col, c.Dist)
 		case "L":
-			pos.Col -= c.Dist
+			pos.Col, ok = util.CheckedAdd(pos.Col, -c.Dist)
 		case "U":
-			pos.Row += c.Dist
+			pos.Row, ok = util.CheckedAdd(pos.Row, c.Dist)
 		case "D":
-			pos.Row -= c.Dist
+			pos.Row, ok = util.CheckedAdd(pos.Row, -c.Dist)
+		}
+		if !ok {
+			return nil, fmt.Errorf("digging %s: %w", c, ErrOverflow)
 		}
 
 		theMap.AddVertex(&Coordinate{pos.Row, pos.Col})
 	}
 
-	return theMap
+	return theMap, nil
 }
 
-// Hello [Shoelace Formula](https://en.wikipedia.org/wiki/Shoelace_formula#Shoelace_formula)!
-func CalculateArea(theMap *Map) float64 {
-	area := 0.0
-	borderLen := 0.0
-	for i := 0; i < len(theMap.VerticesOrdered); i++ {
-		c1 := theMap.VerticesOrdered[i]
-		c2 := theMap.VerticesOrdered[(i+1)%len(theMap.VerticesOrdered)]
-		area += float64(c1.Row*c2.Col - c2.Row*c1.Col)
-		borderLen += math.Abs(float64(c1.Col - c2.Col + c2.Row - c1.Row))
+func abs(a int64) int64 {
+	if a < 0 {
+		return -a
 	}
+	return a
+}
 
-	return area/2 + borderLen/2 + 1
+// Perimeter is the length of the trench, which is also how many cubes it holds.
+func (m *Map) Perimeter() (int64, error) {
+	var perimeter int64
+	for i, c1 := range m.VerticesOrdered {
+		c2 := m.VerticesOrdered[(i+1)%len(m.VerticesOrdered)]
+		var ok bool
+		// only one of these is non zero, every edge is axis aligned
+		if perimeter, ok = util.CheckedAdd(perimeter, abs(c2.Row-c1.Row)+abs(c2.Col-c1.Col)); !ok {
+			return 0, ErrOverflow
+		}
+	}
+	return perimeter, nil
 }
 
-func PrintableGrid(theMap *Map) string {
-	printableGrid := []string{}
-	for row := theMap.MaxY; row >= theMap.MinY; row-- {
-		b := strings.Builder{}
-		b.Grow(theMap.MaxX - theMap.MinX + 1)
-		for col := theMap.MinX; col <= theMap.MaxX; col++ {
-			c := &Coordinate{row, col}
-			if theMap.VerticesMapped[c.String()] {
-				b.WriteString("#")
-			} else {
-				b.WriteString(".")
-			}
+// Hello [Shoelace Formula](https://en.wikipedia.org/wiki/Shoelace_formula#Shoelace_formula)!
+// That gives the area inside the trench's centre line, [Pick's theorem](https://en.wikipedia.org/wiki/Pick%27s_theorem)
+// turns it into the interior cubes, then the trench itself is added back on.
+func (m *Map) Area() (int64, error) {
+	var twiceArea int64
+	for i, c1 := range m.VerticesOrdered {
+		c2 := m.VerticesOrdered[(i+1)%len(m.VerticesOrdered)]
+		a, okA := util.CheckedMul(c1.Row, c2.Col)
+		b, okB := util.CheckedMul(c2.Row, c1.Col)
+		if !okA || !okB {
+			return 0, ErrOverflow
+		}
+		cross, ok := util.CheckedAdd(a, -b)
+		if !ok {
+			return 0, ErrOverflow
 		}
-		printableGrid = append(printableGrid, b.String())
+		if twiceArea, ok = util.CheckedAdd(twiceArea, cross); !ok {
+			return 0, ErrOverflow
+		}
+	}
+
+	perimeter, err := m.Perimeter()
+	if err != nil {
+		return 0, err
 	}
 
-	return strings.Join(printableGrid, "\n")
+	// the loop may run either way round, which only flips the sign
+	interior := abs(twiceArea)/2 - perimeter/2 + 1
+	area, ok := util.CheckedAdd(interior, perimeter)
+	if !ok {
+		return 0, ErrOverflow
+	}
+	return area, nil
 }
 
-func partOne(puzzleFile string) {
-	slog.Info("Day Eighteen part one", "puzzle file", puzzleFile)
+// PrintableGrid draws the trench, shrinking it so the drawing has at most maxCells cells.
+// Each character then covers a square block of the map and is dug if any of it is.
+// A maxCells of zero skips drawing entirely.
+func PrintableGrid(theMap *Map, maxCells int64) (string, int64) {
+	if maxCells <= 0 {
+		return "", 0
+	}
 
-	commands := ParseCommands(puzzleFile)
+	width := theMap.MaxX - theMap.MinX + 1
+	height := theMap.MaxY - theMap.MinY + 1
+	scale := int64(1)
+	// compare each side rather than the product, which can overflow on part two maps
+	for (width+scale-1)/scale > maxCells/((height+scale-1)/scale) {
+		scale *= 2
+	}
+	cols := (width + scale - 1) / scale
+	rows := (height + scale - 1) / scale
+
+	grid := make([][]byte, rows)
+	for i := range grid {
+		grid[i] = []byte(strings.Repeat(".", int(cols)))
+	}
+	dig := func(row, col int64) {
+		grid[(theMap.MaxY-row)/scale][(col-theMap.MinX)/scale] = '#'
+	}
+
+	for i, c1 := range theMap.VerticesOrdered {
+		c2 := theMap.VerticesOrdered[(i+1)%len(theMap.VerticesOrdered)]
+		minRow, maxRow := min(c1.Row, c2.Row), max(c1.Row, c2.Row)
+		minCol, maxCol := min(c1.Col, c2.Col), max(c1.Col, c2.Col)
+		// stepping by the scale still lands in every block the edge crosses
+		for row := minRow; row < maxRow; row += scale {
+			dig(row, minCol)
+		}
+		for col := minCol; col < maxCol; col += scale {
+			dig(minRow, col)
+		}
+		dig(maxRow, maxCol)
+	}
+
+	printableGrid := make([]string, rows)
+	for i, row := range grid {
+		printableGrid[i] = string(row)
+	}
+	return strings.Join(printableGrid, "\n"), scale
+}
 
-	theMap := BuildMap(commands)
+func dig(commands []*DigCommand, maxCells int64, request *render.Request) int64 {
+	theMap, err := BuildMap(commands)
+	if err != nil {
+		log.Fatal(err)
+	}
 	slog.Debug("got a map!", "theMap", theMap)
 
-	printGrid := PrintableGrid(theMap)
+	// drawing a part two trench takes a while, only do it when someone will look
+	debugging := util.InDebugMode()
+	if debugging || request != nil {
+		if printGrid, scale := PrintableGrid(theMap, maxCells); printGrid != "" {
+			slog.Debug("drew the trench", "scale", scale)
+			if debugging {
+				artifacts.Write("trench.txt", []byte(printGrid))
+			}
+			if err := request.Write(strings.Split(printGrid, "\n")); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	perimeter, err := theMap.Perimeter()
+	if err != nil {
+		log.Fatal(err)
+	}
+	filledPositions, err := theMap.Area()
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("dug the trench", "trench length", perimeter)
+	return filledPositions
+}
 
-	filledPositions := CalculateArea(theMap)
+func partOne(puzzleFile string, maxCells int64, request *render.Request) {
+	slog.Info("Day Eighteen part one", "puzzle file", puzzleFile)
 
-	os.WriteFile("/tmp/dayEighteenGrid.txt", []byte(printGrid), 0644)
+	commands := ParseCommands(puzzleFile)
+	filledPositions := dig(commands, maxCells, request)
 
 	slog.Info("finished digging", "filled positions", filledPositions)
 }
 
-func partTwo(puzzleFile string) {
+func partTwo(puzzleFile string, maxCells int64, request *render.Request) {
 	slog.Info("Day Eighteen part two", "puzzle file", puzzleFile)
 
 	bustedCommands := ParseCommands(puzzleFile)
 
 	fixedCommands := []*DigCommand{}
 	for _, bustedCommand := range bustedCommands {
-		dist, _ := strconv.ParseInt(bustedCommand.Color[:5], 16, 32)
-		var dir string
-		switch bustedCommand.Color[5:] {
-		case "0":
-			dir = "R"
-		case "1":
-			dir = "D"
-		case "2":
-			dir = "L"
-		case "3":
-			dir = "U"
+		c, err := bustedCommand.Decode()
+		if err != nil {
+			log.Fatal(err)
 		}
-
-		c := &DigCommand{dir, int(dist), bustedCommand.Color}
 		slog.Debug("fixed command", "bustedCommand", bustedCommand, "c", c)
 		fixedCommands = append(fixedCommands, c)
 	}
 
-	theMap := BuildMap(fixedCommands)
-
-	slog.Debug("built our map", "theMap", theMap)
-
-	filledPositions := CalculateArea(theMap)
-
-	slog.Info("finished digging", "filled positions", fmt.Sprintf("%.0f", filledPositions))
-}
+	filledPositions := dig(fixedCommands, maxCells, request)
 
-type NoopStringBuilder struct{}
-
-func (sb *NoopStringBuilder) WriteString(p string) (n int, err error) {
-	return len(p), nil
-}
-
-func (sb *NoopStringBuilder) Grow(i int) {}
-
-func (sb *NoopStringBuilder) String() string {
-	return ""
+	slog.Info("finished digging", "filled positions", filledPositions)
 }
 
 var Cmd = &cobra.Command{
 	Use: "dayEighteen",
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
+		maxCells, _ := cmd.Flags().GetInt64("max-render-cells")
+		request, err := render.FromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
 		if !cmd.Flag("part-two").Changed {
-			partOne(puzzleInput)
+			partOne(puzzleInput, maxCells, request)
 		} else {
-			partTwo(puzzleInput)
+			partTwo(puzzleInput, maxCells, request)
 		}
 	},
 }
 
 func init() {
 	Cmd.Flags().Bool("part-two", false, "Whether to run part two of the day's challenge")
+	Cmd.Flags().Int64("max-render-cells", 1_000_000, "Largest drawing of the trench, made when debugging or rendering, before it is scaled down, 0 skips drawing")
 }
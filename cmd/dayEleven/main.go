@@ -1,16 +1,21 @@
 package dayEleven
 
 import (
+	"adventofcode/cmd/artifacts"
+	"adventofcode/cmd/coordinates"
+	"adventofcode/cmd/render"
+	"adventofcode/cmd/util"
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
-	"math"
 	"os"
+	"slices"
+	"strings"
 
 	"github.com/spf13/cobra"
-	"gonum.org/v1/gonum/stat/combin"
 )
 
 const ONE_MILLION = 1000000
@@ -18,14 +23,16 @@ const ONE_MILLION = 1000000
 /**
 build list of all galaxy coordinates
 determine rows and columns with no galaxies using that list
-for each galaxy pair in the list, calculate the distance between them
+expand every coordinate by the empty rows or columns before it, counted with prefix sums
+sum the distances along each axis from the sorted coordinates, a galaxy at sorted position i
+is that far past the i galaxies before it
 **/
 
+var ErrOverflow = errors.New("galaxy distances overflow int64")
+
 type Galaxy struct {
-	Id              int
-	X, Y            int
-	ClosestGalaxy   *Galaxy
-	ClosestDistance int
+	Id   int
+	X, Y int
 }
 
 func (g *Galaxy) String() string {
@@ -35,10 +42,10 @@ func (g *Galaxy) String() string {
 
 type Observation struct {
 	Height, Width int
-	Galaxies      map[string]*Galaxy
-	GalaxiesById  map[int]*Galaxy
-	EmptyRows     map[int]bool
-	EmptyCols     map[int]bool
+	Galaxies      []*Galaxy
+	// EmptyRowsBefore[y] counts the galaxy free rows above row y, likewise for columns
+	EmptyRowsBefore []int
+	EmptyColsBefore []int
 }
 
 func (o *Observation) String() string {
@@ -46,53 +53,27 @@ func (o *Observation) String() string {
 	return string(j)
 }
 
-func (o *Observation) PutGalaxy(g *Galaxy) {
-	key := fmt.Sprintf("%d,%d", g.X, g.Y)
-	o.Galaxies[key] = g
-	o.GalaxiesById[g.Id] = g
-}
-
-func (o *Observation) GetGalaxy(x, y int) *Galaxy {
-	key := fmt.Sprintf("%d,%d", x, y)
-	g, _ := o.Galaxies[key]
-	return g
-}
-
-func (o *Observation) GetGalaxyById(id int) *Galaxy {
-	g, _ := o.GalaxiesById[id]
-	return g
-}
-
 func parse(path string) *Observation {
 	f, err := os.Open(path)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer f.Close()
 
 	scanner := bufio.NewScanner(f)
 
-	if err != nil {
-		slog.Error("failed to parse", "err", err)
-		panic(err)
-	}
-
-	observation := &Observation{
-		Galaxies:     map[string]*Galaxy{},
-		GalaxiesById: map[int]*Galaxy{},
-		EmptyRows:    map[int]bool{},
-		EmptyCols:    map[int]bool{},
-	}
-	y := 0
+	observation := &Observation{}
 	rowsWithGalaxies := map[int]bool{}
 	colsWithGalaxies := map[int]bool{}
 	for scanner.Scan() {
+		y := observation.Height
 		for x, c := range scanner.Text() {
-			observation.Width = x
+			observation.Width = max(observation.Width, x+1)
 			if c == '.' {
 				continue
 			}
 
-			observation.PutGalaxy(&Galaxy{
+			observation.Galaxies = append(observation.Galaxies, &Galaxy{
 				Id: len(observation.Galaxies),
 				X:  x,
 				Y:  y,
@@ -100,128 +81,288 @@ func parse(path string) *Observation {
 			rowsWithGalaxies[y] = true
 			colsWithGalaxies[x] = true
 		}
-		observation.Height = y
-		y++
+		observation.Height++
 	}
 
-	slog.Debug("rows with galaxies", "rows", rowsWithGalaxies)
-	for row := 0; row <= observation.Height; row++ {
-		if !rowsWithGalaxies[row] {
-			observation.EmptyRows[row] = true
+	observation.EmptyRowsBefore = emptyBefore(observation.Height, rowsWithGalaxies)
+	observation.EmptyColsBefore = emptyBefore(observation.Width, colsWithGalaxies)
+	slog.Debug("empty space", "rows", observation.EmptyRowsBefore, "cols", observation.EmptyColsBefore)
+
+	return observation
+}
+
+func emptyBefore(size int, withGalaxies map[int]bool) []int {
+	before := make([]int, size+1)
+	for i := 0; i < size; i++ {
+		before[i+1] = before[i]
+		if !withGalaxies[i] {
+			before[i+1]++
 		}
 	}
-	slog.Debug("cols with galaxies", "cols", colsWithGalaxies)
-	for col := 0; col <= observation.Width; col++ {
-		if !colsWithGalaxies[col] {
-			observation.EmptyCols[col] = true
-		}
+	return before
+}
+
+func checkedAdd(a, b int64) (int64, error) {
+	sum, ok := util.CheckedAdd(a, b)
+	if !ok {
+		return 0, ErrOverflow
 	}
+	return sum, nil
+}
 
-	return observation
+func checkedMul(a, b int64) (int64, error) {
+	product, ok := util.CheckedMul(a, b)
+	if !ok {
+		return 0, ErrOverflow
+	}
+	return product, nil
 }
 
-func partOne(puzzleFile string) {
-	observation := parse(puzzleFile)
-	os.WriteFile("inputs/dayElevenObservations.json", []byte(observation.String()), 0644)
-
-	combinationIndices := combin.Combinations(len(observation.Galaxies), 2)
-	sum := 0
-	for _, indices := range combinationIndices {
-		g1 := observation.GetGalaxyById(indices[0])
-		g2 := observation.GetGalaxyById(indices[1])
-		maxX := int(math.Max(float64(g1.X), float64(g2.X)))
-		minX := int(math.Min(float64(g1.X), float64(g2.X)))
-		maxY := int(math.Max(float64(g1.Y), float64(g2.Y)))
-		minY := int(math.Min(float64(g1.Y), float64(g2.Y)))
-
-		// Normal space (manhattan) distance
-		distance := maxX - minX + maxY - minY
-		slog.Debug("combination distance", "combination", indices, "distance", distance)
-		// Add one for every empty row or column between the galaxies
-		for row := minY + 1; row < maxY; row++ {
-			if observation.EmptyRows[row] {
-				distance++
-			}
+// expand moves a coordinate out by factor-1 for every empty line before it.
+func expand(coord int, emptyBefore []int, factor int64) (int64, error) {
+	grown, err := checkedMul(int64(emptyBefore[coord]), factor-1)
+	if err != nil {
+		return 0, err
+	}
+	return checkedAdd(int64(coord), grown)
+}
+
+// ExpandedGalaxy is a galaxy's position once the universe has expanded.
+type ExpandedGalaxy struct {
+	*Galaxy
+	X, Y int64
+}
+
+func (o *Observation) Expand(factor int64) ([]*ExpandedGalaxy, error) {
+	expanded := make([]*ExpandedGalaxy, len(o.Galaxies))
+	for i, g := range o.Galaxies {
+		x, err := expand(g.X, o.EmptyColsBefore, factor)
+		if err != nil {
+			return nil, err
 		}
-		for col := minX + 1; col < maxX; col++ {
-			if observation.EmptyCols[col] {
-				distance++
-			}
+		y, err := expand(g.Y, o.EmptyRowsBefore, factor)
+		if err != nil {
+			return nil, err
 		}
-		if distance < g1.ClosestDistance || g1.ClosestGalaxy == nil {
-			g1.ClosestDistance = distance
-			g1.ClosestGalaxy = g2
+		expanded[i] = &ExpandedGalaxy{g, x, y}
+	}
+	return expanded, nil
+}
+
+// axisDistanceSum adds up |a - b| over every pair of coordinates.
+func axisDistanceSum(coords []int64) (int64, error) {
+	slices.Sort(coords)
+	var sum, prefix int64
+	for i, c := range coords {
+		// c is at least as far out as the i coordinates before it
+		ahead, err := checkedMul(c, int64(i))
+		if err != nil {
+			return 0, err
 		}
-		if distance < g2.ClosestDistance || g2.ClosestGalaxy == nil {
-			g2.ClosestDistance = distance
-			g2.ClosestGalaxy = g1
+		if sum, err = checkedAdd(sum, ahead-prefix); err != nil {
+			return 0, err
 		}
+		if prefix, err = checkedAdd(prefix, c); err != nil {
+			return 0, err
+		}
+	}
+	return sum, nil
+}
+
+// DistanceSum is the sum of manhattan distances between every pair of galaxies.
+func DistanceSum(galaxies []*ExpandedGalaxy) (int64, error) {
+	xs := make([]int64, len(galaxies))
+	ys := make([]int64, len(galaxies))
+	for i, g := range galaxies {
+		xs[i] = g.X
+		ys[i] = g.Y
+	}
+	xSum, err := axisDistanceSum(xs)
+	if err != nil {
+		return 0, err
+	}
+	ySum, err := axisDistanceSum(ys)
+	if err != nil {
+		return 0, err
+	}
+	return checkedAdd(xSum, ySum)
+}
 
-		sum += distance
+func distance(a, b *ExpandedGalaxy) int64 {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	if dx < 0 {
+		dx = -dx
 	}
+	if dy < 0 {
+		dy = -dy
+	}
+	return dx + dy
+}
 
-	slog.Info("Day Eleven part one", "sum", sum)
+type Pair struct {
+	A, B     *ExpandedGalaxy
+	Distance int64
 }
 
-func partTwo(puzzleFile string) {
-	observation := parse(puzzleFile)
-	os.WriteFile("inputs/dayElevenObservations.json", []byte(observation.String()), 0644)
-
-	combinationIndices := combin.Combinations(len(observation.Galaxies), 2)
-	sum := 0
-	for _, indices := range combinationIndices {
-		g1 := observation.GetGalaxyById(indices[0])
-		g2 := observation.GetGalaxyById(indices[1])
-		maxX := int(math.Max(float64(g1.X), float64(g2.X)))
-		minX := int(math.Min(float64(g1.X), float64(g2.X)))
-		maxY := int(math.Max(float64(g1.Y), float64(g2.Y)))
-		minY := int(math.Min(float64(g1.Y), float64(g2.Y)))
-
-		// Normal space (manhattan) distance
-		distance := maxX - minX + maxY - minY
-		slog.Debug("combination distance", "combination", indices, "distance", distance)
-		// Add one for every empty row or column between the galaxies
-		expandedDistances := 0
-		for row := minY + 1; row < maxY; row++ {
-			if observation.EmptyRows[row] {
-				expandedDistances++
-			}
+func (p *Pair) String() string {
+	return fmt.Sprintf("%d (%d,%d) <-> %d (%d,%d): %d", p.A.Id, p.A.X, p.A.Y, p.B.Id, p.B.X, p.B.Y, p.Distance)
+}
+
+// Nearest sweeps the galaxies left to right, only comparing against those still
+// horizontally closer than the best pair so far.
+func Nearest(galaxies []*ExpandedGalaxy) *Pair {
+	sorted := slices.Clone(galaxies)
+	slices.SortFunc(sorted, func(a, b *ExpandedGalaxy) int {
+		switch {
+		case a.X < b.X:
+			return -1
+		case a.X > b.X:
+			return 1
+		default:
+			return 0
 		}
-		for col := minX + 1; col < maxX; col++ {
-			if observation.EmptyCols[col] {
-				expandedDistances++
+	})
+
+	var best *Pair
+	for i, a := range sorted {
+		for _, b := range sorted[i+1:] {
+			if best != nil && b.X-a.X >= best.Distance {
+				break
+			}
+			if d := distance(a, b); best == nil || d < best.Distance {
+				best = &Pair{a, b, d}
 			}
 		}
+	}
+	return best
+}
 
-		distance = (distance - expandedDistances) + expandedDistances*ONE_MILLION
+// Farthest uses the rotated coordinates x+y and x-y, the manhattan distance between two
+// galaxies being the larger of their differences.
+func Farthest(galaxies []*ExpandedGalaxy) *Pair {
+	type extremes struct{ min, max *ExpandedGalaxy }
+	rotations := []func(g *ExpandedGalaxy) int64{
+		func(g *ExpandedGalaxy) int64 { return g.X + g.Y },
+		func(g *ExpandedGalaxy) int64 { return g.X - g.Y },
+	}
 
-		if distance < g1.ClosestDistance || g1.ClosestGalaxy == nil {
-			g1.ClosestDistance = distance
-			g1.ClosestGalaxy = g2
+	var best *Pair
+	for _, rotate := range rotations {
+		e := extremes{galaxies[0], galaxies[0]}
+		for _, g := range galaxies {
+			if rotate(g) < rotate(e.min) {
+				e.min = g
+			}
+			if rotate(g) > rotate(e.max) {
+				e.max = g
+			}
 		}
-		if distance < g2.ClosestDistance || g2.ClosestGalaxy == nil {
-			g2.ClosestDistance = distance
-			g2.ClosestGalaxy = g1
+		if d := distance(e.min, e.max); best == nil || d > best.Distance {
+			best = &Pair{e.min, e.max, d}
 		}
+	}
+	return best
+}
 
-		sum += distance
+// Rows draws the observation back out, before any expansion.
+func (o *Observation) Rows() []string {
+	grid := make([][]byte, o.Height)
+	for y := range grid {
+		grid[y] = []byte(strings.Repeat(".", o.Width))
+	}
+	for _, g := range o.Galaxies {
+		grid[g.Y][g.X] = '#'
+	}
+	rows := make([]string, o.Height)
+	for y, row := range grid {
+		rows[y] = string(row)
 	}
+	return rows
+}
 
-	slog.Info("Day Eleven part two", "sum", sum)
+// renderObservation shades the empty rows and columns that expand and joins each of the
+// pairs given.
+func renderObservation(o *Observation, pairs []*Pair, request *render.Request) {
+	empty := render.Visited{}
+	for y := 0; y < o.Height; y++ {
+		for x := 0; x < o.Width; x++ {
+			if o.EmptyRowsBefore[y+1] > o.EmptyRowsBefore[y] || o.EmptyColsBefore[x+1] > o.EmptyColsBefore[x] {
+				empty = append(empty, coordinates.Coordinate{Row: y, Col: x})
+			}
+		}
+	}
+	overlays := []render.Overlay{empty}
+	for _, p := range pairs {
+		overlays = append(overlays, render.Path{
+			{Row: p.A.Galaxy.Y, Col: p.A.Galaxy.X},
+			{Row: p.B.Galaxy.Y, Col: p.B.Galaxy.X},
+		})
+	}
+	if err := request.Write(o.Rows(), overlays...); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func solve(puzzleFile string, factor int64, extremes bool, request *render.Request) int64 {
+	observation := parse(puzzleFile)
+	artifacts.Write("observations.json", []byte(observation.String()))
+
+	galaxies, err := observation.Expand(factor)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sum, err := DistanceSum(galaxies)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pairs := []*Pair{}
+	if extremes && len(galaxies) > 1 {
+		nearest, farthest := Nearest(galaxies), Farthest(galaxies)
+		fmt.Println("nearest", nearest)
+		fmt.Println("farthest", farthest)
+		pairs = append(pairs, nearest, farthest)
+	}
+	renderObservation(observation, pairs, request)
+	return sum
+}
+
+func partOne(puzzleFile string, factor int64, extremes bool, request *render.Request) {
+	sum := solve(puzzleFile, factor, extremes, request)
+	slog.Info("Day Eleven part one", "expansion factor", factor, "sum", sum)
+}
+
+func partTwo(puzzleFile string, factor int64, extremes bool, request *render.Request) {
+	sum := solve(puzzleFile, factor, extremes, request)
+	slog.Info("Day Eleven part two", "expansion factor", factor, "sum", sum)
 }
 
 var Cmd = &cobra.Command{
 	Use: "dayEleven",
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
+		extremes, _ := cmd.Flags().GetBool("extremes")
+		factor, _ := cmd.Flags().GetInt64("expansion-factor")
+		if factor < 1 {
+			log.Fatalf("expansion factor must be at least 1, got %d", factor)
+		}
+		request, err := render.FromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
 		if !cmd.Flag("part-two").Changed {
-			partOne(puzzleInput)
+			partOne(puzzleInput, factor, extremes, request)
 		} else {
-			partTwo(puzzleInput)
+			if !cmd.Flag("expansion-factor").Changed {
+				factor = ONE_MILLION
+			}
+			partTwo(puzzleInput, factor, extremes, request)
 		}
 	},
 }
 
 func init() {
 	Cmd.Flags().Bool("part-two", false, "Whether to run part two of the day's challenge")
+	Cmd.Flags().Int64("expansion-factor", 2, "How many lines each empty line becomes, defaults to one million for part two")
+	Cmd.Flags().Bool("extremes", false, "Print the nearest and farthest pairs of galaxies")
 }
@@ -0,0 +1,128 @@
+package dayFifteen
+
+import (
+	"fmt"
+	"strings"
+)
+
+const boxCount = 256
+
+type entry[V any] struct {
+	label      string
+	value      V
+	prev, next *entry[V]
+}
+
+// box keeps its entries in a linked list for ordering and a map for finding a label,
+// so replacing or removing a lens never scans the box.
+type box[V any] struct {
+	entries     map[string]*entry[V]
+	first, last *entry[V]
+}
+
+// Hashmap is the HASHMAP from the puzzle: the HASH of a label picks one of 256 boxes and
+// each box remembers the order labels were first put into it.
+type Hashmap[V any] struct {
+	boxes [boxCount]*box[V]
+}
+
+func NewHashmap[V any]() *Hashmap[V] {
+	return &Hashmap[V]{}
+}
+
+// Put replaces the label's value in place or adds it to the back of its box.
+func (h *Hashmap[V]) Put(label string, value V) {
+	i := hash(label)
+	b := h.boxes[i]
+	if b == nil {
+		b = &box[V]{entries: map[string]*entry[V]{}}
+		h.boxes[i] = b
+	}
+
+	if e, ok := b.entries[label]; ok {
+		e.value = value
+		return
+	}
+
+	e := &entry[V]{label: label, value: value, prev: b.last}
+	if b.last != nil {
+		b.last.next = e
+	} else {
+		b.first = e
+	}
+	b.last = e
+	b.entries[label] = e
+}
+
+// Remove takes the label out of its box, the lenses behind it shuffle forward.
+func (h *Hashmap[V]) Remove(label string) {
+	b := h.boxes[hash(label)]
+	if b == nil {
+		return
+	}
+	e, ok := b.entries[label]
+	if !ok {
+		return
+	}
+
+	if e.prev != nil {
+		e.prev.next = e.next
+	} else {
+		b.first = e.next
+	}
+	if e.next != nil {
+		e.next.prev = e.prev
+	} else {
+		b.last = e.prev
+	}
+	delete(b.entries, label)
+}
+
+func (h *Hashmap[V]) Get(label string) (V, bool) {
+	var zero V
+	b := h.boxes[hash(label)]
+	if b == nil {
+		return zero, false
+	}
+	e, ok := b.entries[label]
+	if !ok {
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Each calls fn for every label in the box, front to back.
+func (h *Hashmap[V]) Each(boxNumber int, fn func(slot int, label string, value V)) {
+	b := h.boxes[boxNumber]
+	if b == nil {
+		return
+	}
+	slot := 0
+	for e := b.first; e != nil; e = e.next {
+		fn(slot, e.label, e.value)
+		slot++
+	}
+}
+
+func (h *Hashmap[V]) Len(boxNumber int) int {
+	if h.boxes[boxNumber] == nil {
+		return 0
+	}
+	return len(h.boxes[boxNumber].entries)
+}
+
+// String lists the non-empty boxes like the puzzle statement does.
+func (h *Hashmap[V]) String() string {
+	lines := []string{}
+	for i := 0; i < boxCount; i++ {
+		if h.Len(i) == 0 {
+			continue
+		}
+		line := fmt.Sprintf("Box %d:", i)
+		h.Each(i, func(_ int, label string, value V) {
+			line += fmt.Sprintf(" [%s %v]", label, value)
+		})
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
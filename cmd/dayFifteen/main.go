@@ -3,6 +3,7 @@ package dayFifteen
 import (
 	"adventofcode/cmd/fileReader"
 	"fmt"
+	"log"
 	"log/slog"
 	"strconv"
 	"strings"
@@ -53,56 +54,18 @@ func hash(step string) int {
 	return h
 }
 
-type Lens struct {
-	Label       string
-	FocalLength int
-}
-
-func (l *Lens) String() string {
-	return fmt.Sprintf("[%s %d]", l.Label, l.FocalLength)
-}
-
-type Box struct {
-	id            int
-	lenses        map[string]int
-	orderedLenses []*Lens
-}
-
-func (b *Box) String() string {
-	return fmt.Sprintf("Box %d: %v", b.id, b.orderedLenses)
-}
-
-func (b *Box) focusingPowers() []int {
-	ps := []int{}
-	for i, l := range b.orderedLenses {
-		ps = append(ps, (1+b.id)*(1+i)*l.FocalLength)
-	}
-
-	return ps
-}
-
-func (b *Box) addLens(l *Lens) {
-	if i, ok := b.lenses[l.Label]; ok {
-		b.orderedLenses[i] = l
-		return
+// focusingPowers works out each lens's focusing power, box by box.
+func focusingPowers(lenses *Hashmap[int]) [][]int {
+	powers := make([][]int, boxCount)
+	for box := range powers {
+		lenses.Each(box, func(slot int, _ string, focalLength int) {
+			powers[box] = append(powers[box], (1+box)*(1+slot)*focalLength)
+		})
 	}
-
-	b.orderedLenses = append(b.orderedLenses, l)
-	b.lenses[l.Label] = len(b.orderedLenses) - 1
+	return powers
 }
 
-func (b *Box) removeLens(label string) {
-	if i, ok := b.lenses[label]; ok {
-		b.orderedLenses = append(b.orderedLenses[:i], b.orderedLenses[i+1:]...)
-		delete(b.lenses, label)
-
-		for j := i; j < len(b.orderedLenses); j++ {
-			b.lenses[b.orderedLenses[j].Label] = j
-		}
-	}
-}
-
-func partTwo(puzzleFile string) {
+func partTwo(puzzleFile string, trace, breakdown bool) {
 	slog.Info("Day Fifteen part two", "puzzle file", puzzleFile)
 	/**
 	steps are now
@@ -128,53 +91,39 @@ func partTwo(puzzleFile string) {
 		",",
 	)
 
-	boxes := make(map[int]*Box)
+	lenses := NewHashmap[int]()
 
 	for _, step := range steps {
-		removeSplits := strings.Split(step, "-")
-		if len(removeSplits) > 1 {
-			lensLabel := removeSplits[0]
-			boxNumber := hash(lensLabel)
-
-			if box, ok := boxes[boxNumber]; ok {
-				box.removeLens(lensLabel)
+		if label, found := strings.CutSuffix(step, "-"); found {
+			lenses.Remove(label)
+		} else if label, focalLength, found := strings.Cut(step, "="); found {
+			length, err := strconv.Atoi(focalLength)
+			if err != nil {
+				log.Fatal(err)
 			}
+			lenses.Put(label, length)
 		}
+		slog.Debug("Added a step", "step", step, "boxes", lenses)
 
-		addSplits := strings.Split(step, "=")
-		if len(addSplits) > 1 {
-			lensLabel := addSplits[0]
-			boxNumber := hash(lensLabel)
-			focalLength, _ := strconv.Atoi(addSplits[1])
-
-			if box, ok := boxes[boxNumber]; ok {
-				box.addLens(&Lens{
-					Label:       lensLabel,
-					FocalLength: focalLength,
-				})
-			} else {
-				box := &Box{
-					id:            boxNumber,
-					lenses:        make(map[string]int),
-					orderedLenses: []*Lens{},
-				}
-				box.addLens(&Lens{
-					Label:       lensLabel,
-					FocalLength: focalLength,
-				})
-				boxes[boxNumber] = box
-			}
+		if trace {
+			fmt.Printf("After \"%s\":\n%s\n\n", step, lenses)
 		}
-		slog.Debug("Added a step", "step", step, "boxes", boxes)
 	}
 
 	sum := 0
-	for _, box := range boxes {
-		ps := box.focusingPowers()
-		slog.Debug("Box focusing powers", "box", box.id, "powers", ps)
+	for box, ps := range focusingPowers(lenses) {
+		if len(ps) == 0 {
+			continue
+		}
+		boxSum := 0
 		for _, p := range ps {
-			sum += p
+			boxSum += p
+		}
+		if breakdown {
+			fmt.Printf("Box %d: %v = %d\n", box, ps, boxSum)
 		}
+		slog.Debug("Box focusing powers", "box", box, "powers", ps)
+		sum += boxSum
 	}
 
 	slog.Info("Finished day fifteen part two", "sum", sum)
@@ -187,11 +136,15 @@ var Cmd = &cobra.Command{
 		if !cmd.Flag("part-two").Changed {
 			partOne(puzzleInput)
 		} else {
-			partTwo(puzzleInput)
+			trace, _ := cmd.Flags().GetBool("trace")
+			breakdown, _ := cmd.Flags().GetBool("breakdown")
+			partTwo(puzzleInput, trace, breakdown)
 		}
 	},
 }
 
 func init() {
 	Cmd.Flags().Bool("part-two", false, "Whether to run part two of the day's challenge")
+	Cmd.Flags().Bool("trace", false, "Print the boxes after every step, only applicable for part two")
+	Cmd.Flags().Bool("breakdown", false, "Print each box's focusing powers, only applicable for part two")
 }
@@ -2,9 +2,12 @@ package dayFour
 
 import (
 	"adventofcode/cmd/scanner"
+	"encoding/json"
 	"fmt"
 	"log"
 	"log/slog"
+	"os"
+	"text/tabwriter"
 
 	"github.com/alecthomas/participle/v2"
 	"github.com/spf13/cobra"
@@ -13,36 +16,30 @@ import (
 // Card 1: 41 48 83 86 17 | 83 86  6 31 17  9 48 53
 type Card struct {
 	Id               int   `"Card" @Int ":"`
-	WinningNumbers   []int `@Int+ "|"`
-	PossessedNumbers []int `@Int+`
+	WinningNumbers   []int `@Int* "|"`
+	PossessedNumbers []int `@Int*`
 }
 
 func (c *Card) String() string {
 	return fmt.Sprintf("Card %d: %v | %v", c.Id, c.WinningNumbers, c.PossessedNumbers)
 }
 
-func (c *Card) Points() int {
-	winners := make(map[int]bool)
-	for _, num := range c.WinningNumbers {
-		winners[num] = true
-	}
-
-	points := 0
-	for _, num := range c.PossessedNumbers {
-		if _, ok := winners[num]; ok {
-			if points == 0 {
-				points = 1
-			} else {
-				points *= 2
+// Validate rejects cards listing the same number twice on either side.
+func (c *Card) Validate() error {
+	sides := []string{"winning", "possessed"}
+	for i, numbers := range [][]int{c.WinningNumbers, c.PossessedNumbers} {
+		seen := map[int]bool{}
+		for _, num := range numbers {
+			if seen[num] {
+				return fmt.Errorf("card %d has %d twice in its %s numbers", c.Id, num, sides[i])
 			}
+			seen[num] = true
 		}
 	}
-
-	slog.Debug("points calculation", "card", c, "points", points)
-	return points
+	return nil
 }
 
-func (c *Card) Copies() int {
+func (c *Card) Matches() int {
 	winners := make(map[int]bool)
 	for _, num := range c.WinningNumbers {
 		winners[num] = true
@@ -55,67 +52,118 @@ func (c *Card) Copies() int {
 		}
 	}
 
-	slog.Debug("copies calculation", "card", c, "copies", count)
+	slog.Debug("matches calculation", "card", c, "matches", count)
 	return count
 }
 
-func partOne(puzzleFile string) {
-	fmt.Println("Day template part one", puzzleFile)
+func (c *Card) Points() int {
+	matches := c.Matches()
+	if matches == 0 {
+		return 0
+	}
+	return 1 << (matches - 1)
+}
+
+func readCards(puzzleFile string) []*Card {
 	parser, err := participle.Build[Card]()
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	scanner := scanner.NewScanner[Card](parser, puzzleFile)
-	points := 0
+	cards := []*Card{}
 	for scanner.Scan() {
-		points += scanner.Struct().Points()
+		card := scanner.Struct()
+		if err := card.Validate(); err != nil {
+			log.Fatal(err)
+		}
+		cards = append(cards, card)
 	}
+	return cards
+}
 
-	slog.Info("Total points", "points", points)
+// CardReport is how a single card fared once every copy has been handed out.
+type CardReport struct {
+	Id      int `json:"id"`
+	Matches int `json:"matches"`
+	Points  int `json:"points"`
+	// CopiesWon counts the later cards won by every held copy of this one
+	CopiesWon int `json:"copiesWon"`
+	Held      int `json:"held"`
 }
 
-func partTwo(puzzleFile string) {
-	fmt.Println("Day template part one", puzzleFile)
-	parser, err := participle.Build[Card]()
-	if err != nil {
-		log.Fatal(err)
+// Cascade hands out copies in a single pass. Each card's held count is settled by the
+// time we reach it, and it adds that many copies to the next Matches cards through a
+// running difference array.
+func Cascade(cards []*Card) []*CardReport {
+	reports := make([]*CardReport, len(cards))
+	pending := make([]int, len(cards)+1)
+	extra := 0
+	for i, c := range cards {
+		extra += pending[i]
+		r := &CardReport{
+			Id:      c.Id,
+			Matches: c.Matches(),
+			Points:  c.Points(),
+			Held:    1 + extra,
+		}
+
+		// You can't win copies past the end of the table
+		last := min(i+r.Matches, len(cards)-1)
+		if last > i {
+			pending[i+1] += r.Held
+			pending[last+1] -= r.Held
+			r.CopiesWon = r.Held * (last - i)
+		}
+		reports[i] = r
 	}
+	return reports
+}
 
-	scanner := scanner.NewScanner[Card](parser, puzzleFile)
-	// Store the number of copies granted to followers as we visit each
-	// card. These are cumulative via multiplication.
-	copyTally := map[int]int{}
-	total := 0
-	for scanner.Scan() {
-		thisCard := scanner.Struct()
-		countOfThisCard := 1
-		if c, ok := copyTally[thisCard.Id]; ok {
-			countOfThisCard += c
+func printReport(reports []*CardReport, format string) {
+	switch format {
+	case "json":
+		j, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			log.Fatal(err)
 		}
-		total += countOfThisCard
-
-		slog.Debug(
-			"Copying and counting cards",
-			"card", thisCard,
-			"countOfThisCard", countOfThisCard,
-			"count", total,
-			"copyTally", copyTally,
-			"copies", thisCard.Copies(),
-		)
-		for i := 0; i < countOfThisCard; i++ {
-			cardsToCopy := thisCard.Copies()
-			for j := 1; j <= cardsToCopy; j++ {
-				copyingCard := thisCard.Id + j
-				if _, ok := copyTally[copyingCard]; !ok {
-					copyTally[copyingCard] = 0
-				}
-				copyTally[copyingCard] = copyTally[copyingCard] + 1
-			}
+		fmt.Println(string(j))
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, "card\tmatches\tpoints\tcopies won\theld\t")
+		for _, r := range reports {
+			fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%d\t\n", r.Id, r.Matches, r.Points, r.CopiesWon, r.Held)
 		}
+		w.Flush()
+	case "":
+	default:
+		log.Fatalf("unknown report format %q", format)
 	}
+}
+
+func partOne(puzzleFile string, format string) {
+	slog.Info("Day four part one", "puzzle file", puzzleFile)
+	reports := Cascade(readCards(puzzleFile))
 
-	slog.Debug("Total cards", "count", total, "copyTally", copyTally)
+	points := 0
+	for _, r := range reports {
+		points += r.Points
+	}
+
+	printReport(reports, format)
+	slog.Info("Total points", "points", points)
+}
+
+func partTwo(puzzleFile string, format string) {
+	slog.Info("Day four part two", "puzzle file", puzzleFile)
+	reports := Cascade(readCards(puzzleFile))
+
+	total := 0
+	for _, r := range reports {
+		total += r.Held
+	}
+
+	printReport(reports, format)
 	slog.Info("Total cards", "count", total)
 }
 
@@ -123,10 +171,15 @@ var Cmd = &cobra.Command{
 	Use: "dayFour",
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
+		format, _ := cmd.Flags().GetString("report")
 		if !cmd.Flag("part-two").Changed {
-			partOne(puzzleInput)
+			partOne(puzzleInput, format)
 		} else {
-			partTwo(puzzleInput)
+			partTwo(puzzleInput, format)
 		}
 	},
 }
+
+func init() {
+	Cmd.Flags().String("report", "", "Print a per card breakdown as a table or json")
+}
@@ -2,7 +2,10 @@ package dayFourteen
 
 import (
 	"adventofcode/cmd/fileReader"
+	"adventofcode/cmd/render"
+	"adventofcode/cmd/runs"
 	"fmt"
+	"log"
 	"log/slog"
 	"os"
 	"strings"
@@ -33,16 +36,21 @@ func load(rows []string) int {
 	return loadTotal
 }
 
-func partOne(puzzleFile string) {
+func partOne(puzzleFile string, request *render.Request, recorder *render.Recorder) {
 	slog.Info("Day Fourteen part one", "puzzle file", puzzleFile)
 
 	rows := strings.Split(fileReader.ReadFileContents(puzzleFile), "\n")
-	g := toGrid(rows)
-	for i := range rows[0] {
-		g = tiltNorth(i, g)
+	recorder.Frame(rows)
+	g := tilt(North, toGrid(rows))
+	recorder.Frame(toRows(g))
+	if err := request.Write(toRows(g)); err != nil {
+		log.Fatal(err)
+	}
+	if err := recorder.Save(); err != nil {
+		log.Fatal(err)
 	}
 
-	slog.Info("Day fourteen part one total load", "load", load(rows))
+	slog.Info("Day fourteen part one total load", "load", load(toRows(g)))
 }
 
 func tiltNorth(col int, rows [][]rune) [][]rune {
@@ -131,30 +139,78 @@ func printGrid(dir string, rows [][]rune) {
 	}
 }
 
-func spinCycle(rows [][]rune) [][]rune {
-	for i := range rows[0] {
-		rows = tiltNorth(i, rows)
-	}
-	if os.Getenv("LOG_TILTS") == "YES" {
-		printGrid("North", rows)
+type Tilt rune
+
+const (
+	North Tilt = 'N'
+	West  Tilt = 'W'
+	South Tilt = 'S'
+	East  Tilt = 'E'
+)
+
+func (t Tilt) String() string {
+	switch t {
+	case North:
+		return "North"
+	case West:
+		return "West"
+	case South:
+		return "South"
+	case East:
+		return "East"
+	default:
+		return string(t)
 	}
-	for i := range rows {
-		rows = tiltWest(i, rows)
+}
+
+// ParseSequence reads a cycle like "NWSE" into tilts, skipping any spaces.
+func ParseSequence(sequence string) ([]Tilt, error) {
+	tilts := []Tilt{}
+	for _, r := range strings.ToUpper(sequence) {
+		switch Tilt(r) {
+		case North, West, South, East:
+			tilts = append(tilts, Tilt(r))
+		case ' ':
+			// just spacing
+		default:
+			return nil, fmt.Errorf("unknown tilt %q in sequence %q", r, sequence)
+		}
 	}
-	if os.Getenv("LOG_TILTS") == "YES" {
-		printGrid("West", rows)
+	if len(tilts) == 0 {
+		return nil, fmt.Errorf("sequence %q has no tilts", sequence)
 	}
-	for i := range rows[0] {
-		rows = tiltSouth(i, rows)
+	return tilts, nil
+}
+
+func tilt(t Tilt, rows [][]rune) [][]rune {
+	switch t {
+	case North:
+		for i := range rows[0] {
+			rows = tiltNorth(i, rows)
+		}
+	case West:
+		for i := range rows {
+			rows = tiltWest(i, rows)
+		}
+	case South:
+		for i := range rows[0] {
+			rows = tiltSouth(i, rows)
+		}
+	case East:
+		for i := range rows {
+			rows = tiltEast(i, rows)
+		}
 	}
 	if os.Getenv("LOG_TILTS") == "YES" {
-		printGrid("South", rows)
-	}
-	for i := range rows {
-		rows = tiltEast(i, rows)
+		printGrid(t.String(), rows)
 	}
-	if os.Getenv("LOG_TILTS") == "YES" {
-		printGrid("East", rows)
+	return rows
+}
+
+func spinCycle(sequence []Tilt, rows [][]rune, recorder *render.Recorder) [][]rune {
+	for _, t := range sequence {
+		rows = tilt(t, rows)
+		recorder.Frame(toRows(rows))
 	}
 
 	if os.Getenv("LOG_CYCLES") == "YES" {
@@ -188,40 +244,103 @@ func toRows(grid [][]rune) []string {
 	return rows
 }
 
-func partTwo(puzzleFile string, cycles int) {
-	slog.Info("Day Fourteen part two", "puzzle file", puzzleFile)
+// spins are the platforms seen while spinning, and where they started repeating.
+type spins struct {
+	history [][]string
+	loads   []int
+	// start is the first cycle of the repeat and period how long it is, 0 when nothing repeated
+	start, period int
+}
 
-	rows := strings.Split(fileReader.ReadFileContents(puzzleFile), "\n")
-	g := toGrid(rows)
+// at is which simulated cycle the platform after the given number of cycles looks like,
+// cycles past the repeat wrap back into it.
+func (s *spins) at(cycle int) int {
+	if s.period == 0 || cycle < s.start {
+		return cycle
+	}
+	return s.start + (cycle-s.start)%s.period
+}
+
+// spinUntil runs cycles of the sequence until the platform repeats itself, or the requested
+// number of cycles have been run, keeping every platform it saw so the repeat can be used to
+// jump straight to any later cycle.
+func spinUntil(g [][]rune, sequence []Tilt, cycles int, recorder *render.Recorder) *spins {
+	recorder.Frame(toRows(g))
+	s := &spins{history: [][]string{toRows(g)}, loads: []int{load(toRows(g))}}
+	seenGrids := map[string]int{gridChecksum(g): 0}
+
+	for i := 1; i <= cycles; i++ {
+		g = spinCycle(sequence, g, recorder)
+		checksum := gridChecksum(g)
+		if seen, ok := seenGrids[checksum]; ok {
+			s.start, s.period = seen, i-seen
+			slog.Debug("Day fourteen part two repeat found", "cycle", i, "seen", seen, "period", s.period, "final equivalent", s.at(cycles))
+			return s
+		}
+		seenGrids[checksum] = i
+		s.history = append(s.history, toRows(g))
+		s.loads = append(s.loads, load(toRows(g)))
+		slog.Debug("Day fourteen part two cycle", "cycle", i, "load", s.loads[i])
+	}
+
+	return s
+}
 
-	seenGrids := map[string]int{}
+func partTwo(puzzleFile string, cycles int, sequence []Tilt, printLoads bool, request *render.Request, recorder *render.Recorder) {
+	slog.Info("Day Fourteen part two", "puzzle file", puzzleFile, "sequence", sequence)
 
-	for i := 0; i < cycles; i++ {
-		g = spinCycle(g)
-		if seen, ok := seenGrids[gridChecksum(g)]; ok {
-			slog.Debug("Day fourteen part two repeat found", "cycle", i, "seen", seen)
-			maxSkips := (cycles - i) / (i - seen)
-			i = i + maxSkips*(i-seen)
-			slog.Debug("skipping", "new cycle", i, "maxSkips", maxSkips)
-			continue
+	rows := strings.Split(fileReader.ReadFileContents(puzzleFile), "\n")
+	spun := spinUntil(toGrid(rows), sequence, cycles, recorder)
+	if err := recorder.Save(); err != nil {
+		log.Fatal(err)
+	}
+	final := spun.history[spun.at(cycles)]
+
+	// only the simulated cycles are observed, the rest would just be the repeat over again
+	for i, l := range spun.loads {
+		runs.Observe("load per cycle", float64(i), float64(l))
+	}
+	if printLoads {
+		fmt.Println("cycle,load")
+		for i, l := range spun.loads {
+			fmt.Printf("%d,%d\n", i, l)
+		}
+		// later cycles can be read off the repeat rather than printing a billion lines
+		if spun.period > 0 {
+			fmt.Printf("# repeats from cycle %d every %d cycles\n", spun.start, spun.period)
 		}
-		seenGrids[gridChecksum(g)] = i
-		slog.Debug("Day fourteen part two cycle", "cycle", i, "load", load(toRows(g)))
 	}
 
-	printGrid("Final", g)
-	slog.Info("Day fourteen part two total load", "load", load(toRows(g)))
+	printGrid("Final", toGrid(final))
+	if err := request.Write(final); err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("Day fourteen part two total load", "load", load(final))
 }
 
 var Cmd = &cobra.Command{
 	Use: "dayFourteen",
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
+		request, err := render.FromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
+		recorder, err := render.RecorderFromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
 		if !cmd.Flag("part-two").Changed {
-			partOne(puzzleInput)
+			partOne(puzzleInput, request, recorder)
 		} else {
 			cycles, _ := cmd.Flags().GetInt("cycles")
-			partTwo(puzzleInput, cycles)
+			rawSequence, _ := cmd.Flags().GetString("sequence")
+			printLoads, _ := cmd.Flags().GetBool("print-loads")
+			sequence, err := ParseSequence(rawSequence)
+			if err != nil {
+				log.Fatal(err)
+			}
+			partTwo(puzzleInput, cycles, sequence, printLoads, request, recorder)
 		}
 	},
 }
@@ -229,4 +348,6 @@ var Cmd = &cobra.Command{
 func init() {
 	Cmd.Flags().Bool("part-two", false, "Whether to run part two of the day's challenge")
 	Cmd.Flags().Int("cycles", 1, "Cycles to run, only applicable for part two")
+	Cmd.Flags().String("sequence", "NWSE", "Tilts making up one cycle, only applicable for part two")
+	Cmd.Flags().Bool("print-loads", false, "Print the load after each simulated cycle and where they repeat, only applicable for part two")
 }
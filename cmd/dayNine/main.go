@@ -2,8 +2,10 @@ package dayNine
 
 import (
 	"adventofcode/cmd/scanner"
+	"fmt"
 	"log"
 	"log/slog"
+	"math/big"
 
 	"github.com/alecthomas/participle/v2"
 	"github.com/alecthomas/participle/v2/lexer"
@@ -14,45 +16,96 @@ type Sequence struct {
 	Values []int `@Int+`
 }
 
-func zeroed(row []int) bool {
+func zeroed(row []*big.Int) bool {
 	for _, v := range row {
-		if v != 0 {
+		if v.Sign() != 0 {
 			return false
 		}
 	}
 	return true
 }
 
-func (s *Sequence) extrapolate() (int, int) {
-	rows := [][]int{s.Values}
-	for {
-		lastRow := rows[len(rows)-1]
-		nextRow := []int{}
-		for i := 0; i <= len(lastRow)-2; i++ {
-			nextRow = append(nextRow, lastRow[i+1]-lastRow[i])
-		}
+// Polynomial is the lowest degree polynomial through a sequence, kept in Newton's forward
+// difference form: f(x) = sum over k of C(x, k) * Δ^k f(0).
+type Polynomial struct {
+	Degree      int
+	differences []*big.Int
+}
 
-		rows = append(rows, nextRow)
-		if zeroed(nextRow) {
-			break
-		}
+// binomial is C(x, k), which stays an integer for any integer x, negative ones included.
+func binomial(x int64, k int) *big.Int {
+	numerator := big.NewInt(1)
+	denominator := big.NewInt(1)
+	for i := 0; i < k; i++ {
+		numerator.Mul(numerator, big.NewInt(x-int64(i)))
+		denominator.Mul(denominator, big.NewInt(int64(i+1)))
+	}
+	return numerator.Quo(numerator, denominator)
+}
+
+// At evaluates the polynomial at x, where the sequence's first value sits at x = 0.
+func (p *Polynomial) At(x int64) *big.Int {
+	value := new(big.Int)
+	for k, d := range p.differences {
+		value.Add(value, new(big.Int).Mul(binomial(x, k), d))
+	}
+	return value
+}
+
+// Polynomial builds difference rows until one is all zeros. A sequence that runs out of
+// values first doesn't pin down a polynomial, so that's an error.
+func (s *Sequence) Polynomial() (*Polynomial, error) {
+	row := make([]*big.Int, len(s.Values))
+	for i, v := range s.Values {
+		row[i] = big.NewInt(int64(v))
 	}
 
-	for i := len(rows) - 2; i >= 0; i-- {
-		curNum := rows[i][len(rows[i])-1]
-		belowNum := rows[i+1][len(rows[i+1])-1]
-		rows[i] = append(rows[i], curNum+belowNum)
+	p := &Polynomial{}
+	for !zeroed(row) {
+		if len(row) < 2 {
+			return nil, fmt.Errorf("sequence %v never reaches a row of zeros", s.Values)
+		}
+		p.differences = append(p.differences, row[0])
+		nextRow := make([]*big.Int, len(row)-1)
+		for i := range nextRow {
+			nextRow[i] = new(big.Int).Sub(row[i+1], row[i])
+		}
+		row = nextRow
+	}
+	if len(p.differences) > 0 {
+		p.Degree = len(p.differences) - 1
 	}
-	slog.Debug("extrapolated forward", "rows", rows)
 
-	for i := len(rows) - 2; i >= 0; i-- {
-		curNum := rows[i][0]
-		belowNum := rows[i+1][0]
-		rows[i] = append([]int{curNum - belowNum}, rows[i]...)
+	slog.Debug("found polynomial", "values", s.Values, "degree", p.Degree, "differences", p.differences)
+	return p, nil
+}
+
+type Direction string
+
+const (
+	Forward  Direction = "forward"
+	Backward Direction = "backward"
+)
+
+// Extrapolate returns the next steps values past either end of the sequence, nearest first.
+func (s *Sequence) Extrapolate(steps int, direction Direction) ([]*big.Int, int, error) {
+	p, err := s.Polynomial()
+	if err != nil {
+		return nil, 0, err
 	}
-	slog.Debug("extrapolated backward", "rows", rows)
 
-	return rows[0][0], rows[0][len(rows[0])-1]
+	values := []*big.Int{}
+	for step := 1; step <= steps; step++ {
+		switch direction {
+		case Forward:
+			values = append(values, p.At(int64(len(s.Values)-1+step)))
+		case Backward:
+			values = append(values, p.At(int64(-step)))
+		default:
+			return nil, 0, fmt.Errorf("unknown direction %q", direction)
+		}
+	}
+	return values, p.Degree, nil
 }
 
 func newScanner(puzzleFile string) *scanner.PuzzleScanner[Sequence] {
@@ -71,46 +124,61 @@ func newScanner(puzzleFile string) *scanner.PuzzleScanner[Sequence] {
 	return scanner.NewScanner[Sequence](parser, puzzleFile)
 }
 
-func partOne(puzzleFile string) {
-	slog.Info("Day Nine part one", "puzzle file", puzzleFile)
-
+// sumExtrapolations adds up the value steps away from each sequence in the direction.
+func sumExtrapolations(puzzleFile string, steps int, direction Direction) *big.Int {
 	s := newScanner(puzzleFile)
-	sum := 0
+	sum := new(big.Int)
 	for s.Scan() {
 		seq := s.Struct()
-		_, v := seq.extrapolate()
-		sum += v
+		values, degree, err := seq.Extrapolate(steps, direction)
+		if err != nil {
+			log.Fatal(err)
+		}
+		slog.Info("extrapolated", "sequence", seq.Values, "degree", degree, "direction", direction, "values", values)
+		sum.Add(sum, values[len(values)-1])
 	}
-
-	slog.Info("Finished day nine part one", "sum", sum)
+	return sum
 }
 
-func partTwo(puzzleFile string) {
-	slog.Info("Day Nine part two", "puzzle file", puzzleFile)
+// solve sums the extrapolations, part one and two only differ in the direction they go.
+func solve(puzzleFile string, part string, steps int, direction Direction) {
+	slog.Info("Day Nine part "+part, "puzzle file", puzzleFile)
 
-	s := newScanner(puzzleFile)
-	sum := 0
-	for s.Scan() {
-		seq := s.Struct()
-		v, _ := seq.extrapolate()
-		sum += v
-	}
+	sum := sumExtrapolations(puzzleFile, steps, direction)
 
-	slog.Info("Finished day nine part two", "sum", sum)
+	slog.Info("Finished day nine part "+part, "sum", sum)
 }
 
 var Cmd = &cobra.Command{
 	Use: "dayNine",
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
+		steps, _ := cmd.Flags().GetInt("steps")
+		if steps < 1 {
+			log.Fatalf("steps must be at least 1, got %d", steps)
+		}
+		direction := Forward
+		if cmd.Flag("part-two").Changed {
+			direction = Backward
+		}
+		if cmd.Flag("direction").Changed {
+			d, _ := cmd.Flags().GetString("direction")
+			direction = Direction(d)
+		}
+		if direction != Forward && direction != Backward {
+			log.Fatalf("unknown direction %q", direction)
+		}
+
 		if !cmd.Flag("part-two").Changed {
-			partOne(puzzleInput)
+			solve(puzzleInput, "one", steps, direction)
 		} else {
-			partTwo(puzzleInput)
+			solve(puzzleInput, "two", steps, direction)
 		}
 	},
 }
 
 func init() {
 	Cmd.Flags().Bool("part-two", false, "Whether to run part two of the day's challenge")
+	Cmd.Flags().Int("steps", 1, "How far past the end of each sequence to extrapolate")
+	Cmd.Flags().String("direction", "forward", "Extrapolate forward or backward, defaults to backward for part two")
 }
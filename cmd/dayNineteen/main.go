@@ -2,8 +2,11 @@ package dayNineteen
 
 import (
 	"adventofcode/cmd/fileReader"
+	"adventofcode/cmd/graph"
 	"fmt"
+	"log"
 	"log/slog"
+	"slices"
 	"strings"
 
 	"github.com/alecthomas/participle/v2"
@@ -12,29 +15,17 @@ import (
 )
 
 type Workflow struct {
-	Name        string        `@Ident "{"`
-	Rules       []*Rule       `@@*`
-	DefaultRule string        `@Ident "}"`
-	ValidRanges []*ValidRange // the ranges that lead to an A for this workflow
+	Name        string  `@Ident "{"`
+	Rules       []*Rule `@@*`
+	DefaultRule string  `@Ident "}"`
 }
 
 func (w *Workflow) String() string {
-	return fmt.Sprintf("Workflow: %s, Rules: %v, Ranges: %v", w.Name, w.Rules, w.ValidRanges)
-}
-
-func (w *Workflow) Eval(p *Part) string {
-	for _, r := range w.Rules {
-		n := r.Eval(p)
-		if n != "" {
-			slog.Debug("Matched workflow rule", "rule", r, "part", p, "next", n)
-			return n
-		}
-	}
-	return w.DefaultRule
+	return fmt.Sprintf("Workflow: %s, Rules: %v", w.Name, w.Rules)
 }
 
 type Rule struct {
-	Category    string `@( "x" | "m" | "a" | "s")`
+	Category    string `@Ident`
 	Comparator  string `@(">"|"<")`
 	Value       int    `@Int ":"`
 	Destination string `@Ident ","`
@@ -44,36 +35,23 @@ func (r *Rule) String() string {
 	return fmt.Sprintf("Rule: %s %s %d -> %s", r.Category, r.Comparator, r.Value, r.Destination)
 }
 
-func (r *Rule) Eval(p *Part) string {
-	var pVal int
-	switch r.Category {
-	case "x":
-		pVal = p.XRating
-	case "m":
-		pVal = p.MRating
-	case "a":
-		pVal = p.ARating
-	case "s":
-		pVal = p.SRating
-	}
-
+func (r *Rule) Matches(p *Part) bool {
+	// parts are validated against every category before they are routed
+	pVal, _ := p.Rating(r.Category)
 	switch r.Comparator {
 	case ">":
-		if pVal > r.Value {
-			return r.Destination
-		}
+		return pVal > r.Value
 	case "<":
-		if pVal < r.Value {
-			return r.Destination
-		}
+		return pVal < r.Value
 	}
-
-	return ""
+	return false
 }
 
 type Flower struct {
 	orderedWorkflows []*Workflow
 	mappedWorkflows  map[string]*Workflow
+	// Categories are every rating named by a part or a rule, in the order they were first seen
+	Categories []string
 }
 
 func (f *Flower) String() string {
@@ -85,51 +63,88 @@ func (f *Flower) AddWorkflow(w *Workflow) {
 	f.mappedWorkflows[w.Name] = w
 }
 
-func (f *Flower) Process(p *Part) bool {
-	w := f.mappedWorkflows["in"]
-	for {
-		n := w.Eval(p)
-		switch n {
-		case "A":
-			return true
-		case "R":
-			return false
-		default:
-			w = f.mappedWorkflows[n]
+func (f *Flower) addCategory(category string) {
+	if !slices.Contains(f.Categories, category) {
+		f.Categories = append(f.Categories, category)
+	}
+}
+
+// Validate makes sure the part has a single rating for every category.
+func (f *Flower) Validate(p *Part) error {
+	seen := map[string]bool{}
+	for _, r := range p.Ratings {
+		if seen[r.Category] {
+			return fmt.Errorf("part %s rates %s twice", p, r.Category)
 		}
+		seen[r.Category] = true
 	}
+	for _, c := range f.Categories {
+		if !seen[c] {
+			return fmt.Errorf("part %s has no %s rating", p, c)
+		}
+	}
+	return nil
+}
+
+// Rating is one named category of a part, like x=787.
+type Rating struct {
+	Category string `@Ident "="`
+	Value    int    `@Int`
 }
 
 type Part struct {
-	XRating int `"{" "x" "=" @Int`
-	MRating int `"," "m" "=" @Int`
-	ARating int `"," "a" "=" @Int`
-	SRating int `"," "s" "=" @Int "}"`
+	Ratings []*Rating `"{" @@ ( "," @@ )* "}"`
 }
 
 func (p *Part) String() string {
-	return fmt.Sprintf("Part: %d %d %d %d", p.XRating, p.MRating, p.ARating, p.SRating)
+	ratings := make([]string, len(p.Ratings))
+	for i, r := range p.Ratings {
+		ratings[i] = fmt.Sprintf("%s=%d", r.Category, r.Value)
+	}
+	return "{" + strings.Join(ratings, ",") + "}"
 }
 
-func (p *Part) TotalRating() int {
-	return p.XRating + p.MRating + p.ARating + p.SRating
+func (p *Part) Rating(category string) (int, bool) {
+	for _, r := range p.Ratings {
+		if r.Category == category {
+			return r.Value, true
+		}
+	}
+	return 0, false
 }
 
-func ParseCommands(puzzleFile string) (*Flower, []*Part) {
-	lines := strings.Split(fileReader.ReadFileContents(puzzleFile), "\n")
+func (p *Part) TotalRating() int {
+	total := 0
+	for _, r := range p.Ratings {
+		total += r.Value
+	}
+	return total
+}
 
-	myLexer := lexer.MustSimple([]lexer.SimpleRule{
+var (
+	myLexer = lexer.MustSimple([]lexer.SimpleRule{
 		// Order matters here! Int kept stealing the leading cards before I changed the ordering.
-		{"Ident", `[a-zAR]+`},
+		{"Ident", `[a-zA-Z_][a-zA-Z0-9_]*`},
 		{"Int", `\d+`},
 		{"Punct", `[-[!@#$%^&*()+_={}\|:;"'<,>.?/]|]`},
 	})
-	workflowParser := participle.MustBuild[Workflow](
+	workflowParser = participle.MustBuild[Workflow](
 		participle.Lexer(myLexer),
+		// a rule and the default both start with an Ident
+		participle.UseLookahead(2),
 	)
-	partParser := participle.MustBuild[Part](
+	partParser = participle.MustBuild[Part](
 		participle.Lexer(myLexer),
 	)
+)
+
+// ParsePart reads a single part like {x=787,m=2655,a=1222,s=2876}.
+func ParsePart(s string) (*Part, error) {
+	return partParser.ParseString("", s)
+}
+
+func ParseCommands(puzzleFile string) (*Flower, []*Part) {
+	lines := strings.Split(fileReader.ReadFileContents(puzzleFile), "\n")
 
 	flower := &Flower{
 		orderedWorkflows: []*Workflow{},
@@ -161,19 +176,97 @@ func ParseCommands(puzzleFile string) (*Flower, []*Part) {
 		parts = append(parts, p)
 	}
 
+	// Parts name their categories in order, so take those first
+	for _, p := range parts {
+		for _, r := range p.Ratings {
+			flower.addCategory(r.Category)
+		}
+	}
+	for _, w := range flower.orderedWorkflows {
+		for _, r := range w.Rules {
+			flower.addCategory(r.Category)
+		}
+	}
+	for _, p := range parts {
+		if err := flower.Validate(p); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	return flower, parts
 }
 
-func partOne(puzzleFile string) {
+type treeOptions struct {
+	// Export writes the compiled tree, highlighting the route of Query when there is one
+	Export *graph.Request
+	// Boxes prints every accepted box of parts
+	Boxes bool
+	// Query is a part to route through the tree
+	Query string
+	// Domain is the range every category can be rated in
+	Domain Interval
+}
+
+// compileAndReport compiles the workflows then does whatever exporting and querying was asked for.
+func compileAndReport(flower *Flower, options *treeOptions) (*Node, *Space) {
+	root, err := flower.Compile()
+	if err != nil {
+		log.Fatal(err)
+	}
+	space := &Space{flower.Categories, options.Domain}
+	slog.Debug("rating space", "space", space)
+
+	analysis := flower.Analyze(root, space.NewBox())
+	for _, w := range analysis.Unreachable {
+		slog.Warn("unreachable workflow", "workflow", w)
+	}
+	for _, n := range analysis.DeadRules {
+		slog.Warn("rule can never fire", "rule", n)
+	}
+
+	if options.Boxes {
+		for _, b := range root.AcceptedBoxes(space.NewBox()) {
+			fmt.Println(b, b.Combinations())
+		}
+	}
+
+	route := []Step{}
+	if options.Query != "" {
+		p, err := ParsePart(options.Query)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := flower.Validate(p); err != nil {
+			log.Fatal(err)
+		}
+		steps, outcome := root.Route(p)
+		for _, s := range steps {
+			fmt.Println(s)
+		}
+		fmt.Println(p, outcome)
+		route = steps
+	}
+
+	if options.Export != nil {
+		if err := options.Export.Write(root.Graph(route)); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	return root, space
+}
+
+func partOne(puzzleFile string, options *treeOptions) {
 	slog.Info("Day Nineteen part one", "puzzle file", puzzleFile)
 
 	flower, parts := ParseCommands(puzzleFile)
+	root, _ := compileAndReport(flower, options)
 
 	slog.Debug("Parsed", "workflows", flower, "parts", parts)
 
 	acceptedRatings := 0
 	for _, p := range parts {
-		if flower.Process(p) {
+		if _, outcome := root.Route(p); outcome == Accepted {
 			acceptedRatings += p.TotalRating()
 		}
 	}
@@ -185,14 +278,30 @@ var Cmd = &cobra.Command{
 	Use: "dayNineteen",
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
+		export, err := graph.FromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
+		options := &treeOptions{Export: export}
+		options.Boxes, _ = cmd.Flags().GetBool("boxes")
+		options.Query, _ = cmd.Flags().GetString("part")
+		options.Domain.Min, _ = cmd.Flags().GetInt("min-rating")
+		options.Domain.Max, _ = cmd.Flags().GetInt("max-rating")
+		if options.Domain.Empty() {
+			log.Fatalf("min rating %d is above max rating %d", options.Domain.Min, options.Domain.Max)
+		}
 		if !cmd.Flag("part-two").Changed {
-			partOne(puzzleInput)
+			partOne(puzzleInput, options)
 		} else {
-			partTwo(puzzleInput)
+			partTwo(puzzleInput, options)
 		}
 	},
 }
 
 func init() {
 	Cmd.Flags().Bool("part-two", false, "Whether to run part two of the day's challenge")
+	Cmd.Flags().Bool("boxes", false, "Print every accepted range of ratings")
+	Cmd.Flags().Int("min-rating", 1, "Lowest rating any category can have")
+	Cmd.Flags().Int("max-rating", 4000, "Highest rating any category can have")
+	Cmd.Flags().String("part", "", "Route a single part like '{x=787,m=2655,a=1222,s=2876}' and show the way it went")
 }
@@ -0,0 +1,238 @@
+package dayNineteen
+
+import (
+	"adventofcode/cmd/graph"
+	"fmt"
+)
+
+const (
+	Accepted = "A"
+	Rejected = "R"
+)
+
+// Node is one decision in the compiled workflows. A node with a Rule sends parts matching it
+// to Pass and everything else to Fail, one without is a workflow's default and always goes to
+// Pass. Leaves only have an Outcome.
+type Node struct {
+	Workflow   *Workflow
+	Rule       *Rule
+	Pass, Fail *Node
+	Outcome    string
+}
+
+func (n *Node) IsLeaf() bool {
+	return n.Outcome != ""
+}
+
+func (n *Node) String() string {
+	switch {
+	case n.IsLeaf():
+		return n.Outcome
+	case n.Rule == nil:
+		return fmt.Sprintf("%s: default", n.Workflow.Name)
+	default:
+		return fmt.Sprintf("%s: %s%s%d", n.Workflow.Name, n.Rule.Category, n.Rule.Comparator, n.Rule.Value)
+	}
+}
+
+// Compile turns the workflows into a decision tree rooted at "in". Workflows reached from
+// more than one place share their subtree.
+func (f *Flower) Compile() (*Node, error) {
+	c := &compiler{
+		flower:   f,
+		compiled: map[string]*Node{},
+		visiting: map[string]bool{},
+		leaves:   map[string]*Node{Accepted: {Outcome: Accepted}, Rejected: {Outcome: Rejected}},
+	}
+	return c.workflow("in")
+}
+
+type compiler struct {
+	flower   *Flower
+	compiled map[string]*Node
+	visiting map[string]bool
+	leaves   map[string]*Node
+}
+
+func (c *compiler) workflow(name string) (*Node, error) {
+	if leaf, ok := c.leaves[name]; ok {
+		return leaf, nil
+	}
+	if n, ok := c.compiled[name]; ok {
+		return n, nil
+	}
+	w, ok := c.flower.mappedWorkflows[name]
+	if !ok {
+		return nil, fmt.Errorf("workflow %q does not exist", name)
+	}
+	if c.visiting[name] {
+		return nil, fmt.Errorf("workflow %q loops back on itself", name)
+	}
+	c.visiting[name] = true
+
+	n, err := c.rule(w, 0)
+	if err != nil {
+		return nil, err
+	}
+	c.compiled[name] = n
+	return n, nil
+}
+
+// rule compiles the workflow from its i'th rule onwards.
+func (c *compiler) rule(w *Workflow, i int) (*Node, error) {
+	if i == len(w.Rules) {
+		pass, err := c.workflow(w.DefaultRule)
+		if err != nil {
+			return nil, err
+		}
+		return &Node{Workflow: w, Pass: pass}, nil
+	}
+
+	r := w.Rules[i]
+	pass, err := c.workflow(r.Destination)
+	if err != nil {
+		return nil, err
+	}
+	fail, err := c.rule(w, i+1)
+	if err != nil {
+		return nil, err
+	}
+	return &Node{Workflow: w, Rule: r, Pass: pass, Fail: fail}, nil
+}
+
+// Step is a single decision made while routing a part.
+type Step struct {
+	Node   *Node
+	Passed bool
+}
+
+func (s Step) String() string {
+	if s.Node.Rule == nil {
+		return fmt.Sprintf("%s -> %s", s.Node, s.Node.Pass.destination())
+	}
+	if s.Passed {
+		return fmt.Sprintf("%s true -> %s", s.Node, s.Node.Pass.destination())
+	}
+	return fmt.Sprintf("%s false", s.Node)
+}
+
+// destination names where a node leads, the workflow it starts or its outcome.
+func (n *Node) destination() string {
+	if n.IsLeaf() {
+		return n.Outcome
+	}
+	return n.Workflow.Name
+}
+
+// Route follows a part down the tree, returning every decision on the way and the outcome.
+func (n *Node) Route(p *Part) ([]Step, string) {
+	steps := []Step{}
+	for !n.IsLeaf() {
+		passed := n.Rule == nil || n.Rule.Matches(p)
+		steps = append(steps, Step{n, passed})
+		if passed {
+			n = n.Pass
+		} else {
+			n = n.Fail
+		}
+	}
+	return steps, n.Outcome
+}
+
+// walk visits every node once, parents before children.
+func (n *Node) walk(visit func(*Node)) {
+	seen := map[*Node]bool{}
+	var walk func(*Node)
+	walk = func(n *Node) {
+		if n == nil || seen[n] {
+			return
+		}
+		seen[n] = true
+		visit(n)
+		walk(n.Pass)
+		walk(n.Fail)
+	}
+	walk(n)
+}
+
+func (n *Node) ids() map[*Node]string {
+	ids := map[*Node]string{}
+	n.walk(func(node *Node) {
+		if node.IsLeaf() {
+			ids[node] = node.Outcome
+		} else {
+			ids[node] = fmt.Sprintf("n%d", len(ids))
+		}
+	})
+	return ids
+}
+
+// Graph lays the tree out for exporting, highlighting the decisions on a route when one is given.
+func (n *Node) Graph(route []Step) *graph.Graph {
+	ids := n.ids()
+	g := graph.New("workflows", true)
+	n.walk(func(node *Node) {
+		if node.IsLeaf() {
+			g.AddNode(ids[node], node.Outcome, graph.DoubleCircle)
+			return
+		}
+		g.AddNode(ids[node], node.String(), graph.Box)
+		if node.Rule == nil {
+			g.AddEdge(ids[node], ids[node.Pass], "")
+			return
+		}
+		g.AddEdge(ids[node], ids[node.Pass], "true")
+		g.AddEdge(ids[node], ids[node.Fail], "false")
+	})
+
+	if len(route) > 0 {
+		path := []string{}
+		for _, s := range route {
+			path = append(path, ids[s.Node])
+		}
+		last := route[len(route)-1]
+		if last.Passed {
+			path = append(path, ids[last.Node.Pass])
+		} else {
+			path = append(path, ids[last.Node.Fail])
+		}
+		g.HighlightPath(path...)
+	}
+	return g
+}
+
+// Analysis lists the parts of the workflows that can never matter.
+type Analysis struct {
+	// Unreachable workflows are never sent to from "in"
+	Unreachable []string
+	// DeadRules can never fire, nothing reaching them matches. A node without a rule is a
+	// workflow's default.
+	DeadRules []*Node
+}
+
+// Analyze pushes the whole rating space through the tree, rules that only ever receive an
+// empty box can never fire.
+func (f *Flower) Analyze(root *Node, space Box) *Analysis {
+	fired := map[*Node]bool{}
+	root.split(space, func(n *Node, pass Box) {
+		fired[n] = true
+	}, func(Box, string) {})
+
+	a := &Analysis{}
+	reached := map[string]bool{}
+	root.walk(func(n *Node) {
+		if n.IsLeaf() {
+			return
+		}
+		reached[n.Workflow.Name] = true
+		if !fired[n] {
+			a.DeadRules = append(a.DeadRules, n)
+		}
+	})
+	for _, w := range f.orderedWorkflows {
+		if !reached[w.Name] {
+			a.Unreachable = append(a.Unreachable, w.Name)
+		}
+	}
+	return a
+}
@@ -48,127 +48,39 @@ func (k Kind) String() string {
 	}
 }
 
-var cardOrdering = map[byte]int{
-	'A': 14,
-	'K': 13,
-	'Q': 12,
-	'J': 11,
-	'T': 10,
-	'9': 9,
-	'8': 8,
-	'7': 7,
-	'6': 6,
-	'5': 5,
-	'4': 4,
-	'3': 3,
-	'2': 2,
-}
-
 type Hand struct {
-	Cards        string `@Cards`
-	Bid          int    `@Int`
-	AllowsJokers bool
-	kind         Kind
-}
-
-func complexHandKind(cardCounts map[rune]int) Kind {
-	fourOfAKind := 0
-	threeOfAKind := 0
-	twoOfAKind := 0
-	for _, count := range cardCounts {
-		switch count {
-		case 4:
-			fourOfAKind++
-		case 3:
-			threeOfAKind++
-		case 2:
-			twoOfAKind++
-		}
-	}
-
-	if fourOfAKind > 0 {
-		return FourOfAKind
-	} else if threeOfAKind > 0 && twoOfAKind > 0 {
-		return FullHouse
-	} else if threeOfAKind > 0 {
-		return ThreeOfAKind
-	}
-
-	return TwoPair
+	Cards  string `@Cards`
+	Bid    int    `@Int`
+	Rules  *Rules
+	kind   Kind
+	counts []int
+	wilds  int
 }
 
 func (h *Hand) Kind() Kind {
 	if h.kind != Unknown {
 		return h.kind
 	}
-	cardCounts := map[rune]int{}
-
-	for _, card := range h.Cards {
-		if _, ok := cardCounts[card]; !ok {
-			cardCounts[card] = 0
-		}
-		cardCounts[card] = cardCounts[card] + 1
-	}
-
-	if jCount, ok := cardCounts['J']; ok && h.AllowsJokers {
-		// We can use _any_ jokers to increase the highest count so far
-		// By increasing our best count so far, we increase our hand value
-		maxCard := '0'
-		maxCount := 0
-		for card, count := range cardCounts {
-			if card == 'J' {
-				continue
-			}
-			if count > maxCount {
-				maxCard = card
-				maxCount = count
-			}
-		}
-		if maxCard != '0' {
-			cardCounts[maxCard] = cardCounts[maxCard] + jCount
-			cardCounts['J'] = 0
-			slog.Debug("increased count with joker", "cardCounts", cardCounts)
-			delete(cardCounts, 'J')
-			slog.Debug("increased count with joker", "cardCounts", cardCounts)
-		}
-	}
-
-	switch len(cardCounts) {
-	case 1:
-		h.kind = FiveOfAKind
-	case 2:
-		h.kind = complexHandKind(cardCounts)
-	case 3:
-		h.kind = complexHandKind(cardCounts)
-	case 4:
-		h.kind = OnePair
-	default:
-		h.kind = HighCard
-	}
+	h.kind, h.counts, h.wilds = h.Rules.Kind(h.Cards)
+	slog.Debug("scored hand", "cards", h.Cards, "counts", h.counts, "wilds", h.wilds, "kind", h.kind)
 	return h.kind
 }
 
 func (h *Hand) Less(other *Hand) bool {
-	if h.Kind() == other.Kind() {
-		for i := range h.Cards {
-			hOrder := cardOrdering[h.Cards[i]]
-			otherOrder := cardOrdering[other.Cards[i]]
-			if hOrder == otherOrder {
-				continue
-			}
-
-			return hOrder < otherOrder
-		}
-	}
-	// kind values are in reverse order
-	if h.Kind() < other.Kind() {
-		return false
-	}
-	return true
+	return h.Rules.Less(h, other)
 }
 
 func (h *Hand) String() string {
-	return fmt.Sprintf("Hand{Cards: %s, Bid: %d, Kind: %s, AllowJokers: %t}", h.Cards, h.Bid, h.Kind(), h.AllowsJokers)
+	return fmt.Sprintf("Hand{Cards: %s, Bid: %d, Kind: %s, Rules: %s}", h.Cards, h.Bid, h.Kind(), h.Rules.Name)
+}
+
+// Explain describes why the hand got its kind and rank.
+func (h *Hand) Explain(rank int) string {
+	strengths := h.Rules.tieBreakStrengths(h.Cards)
+	return fmt.Sprintf(
+		"rank %d: %s is %s from groups %v (%d wildcards), tie break %s on %v, winnings %d*%d=%d",
+		rank, h.Cards, h.Kind(), h.counts, h.wilds, h.Rules.TieBreak, strengths, h.Bid, rank, h.Bid*rank,
+	)
 }
 
 type HandHeap []*Hand
@@ -193,10 +105,11 @@ func (h *HandHeap) Pop() any {
 	return x
 }
 
-func partOne(puzzleFile string) {
+// rankHands reads every hand and orders them weakest first under the rules.
+func rankHands(puzzleFile string, rules *Rules) []*Hand {
 	handLexer := lexer.MustSimple([]lexer.SimpleRule{
 		// Order matters here! Int kept stealing the leading cards before I changed the ordering.
-		{"Cards", `[AKQJT98765432]{5}`},
+		{"Cards", rules.CardsPattern()},
 		{"Int", `(\d*\.)?\d+`},
 		{"EOL", `\n`},
 		{"Colon", `:`},
@@ -215,77 +128,95 @@ func partOne(puzzleFile string) {
 	handHeap := &HandHeap{}
 	for scanner.Scan() {
 		hand := scanner.Struct()
+		hand.Rules = rules
 		heap.Push(handHeap, hand)
 	}
 
 	ordered := []*Hand{}
-	totalWinnings := 0
-	rank := 0
 	for handHeap.Len() > 0 {
-		rank++
-		h := heap.Pop(handHeap).(*Hand)
-		totalWinnings += h.Bid * rank
-		ordered = append(ordered, h)
+		ordered = append(ordered, heap.Pop(handHeap).(*Hand))
 	}
-
-	slog.Debug("finished computing!", "ordered hands", ordered)
-	slog.Info("Day seven part one", "total winnings", totalWinnings)
+	return ordered
 }
 
-func partTwo(puzzleFile string) {
-	// Jokers are now wild
-	cardOrdering['J'] = 1
-	handLexer := lexer.MustSimple([]lexer.SimpleRule{
-		// Order matters here! Int kept stealing the leading cards before I changed the ordering.
-		{"Cards", `[AKQJT98765432]{5}`},
-		{"Int", `(\d*\.)?\d+`},
-		{"EOL", `\n`},
-		{"Colon", `:`},
-		{"Whitespace", `[ \t]+`},
-	})
-	parser, err := participle.Build[Hand](
-		participle.Lexer(handLexer),
-		participle.Elide("Whitespace"),
-	)
-	if err != nil {
-		log.Fatal(err)
+func totalWinnings(ordered []*Hand, explain bool) int {
+	total := 0
+	for i, h := range ordered {
+		rank := i + 1
+		total += h.Bid * rank
+		if explain {
+			fmt.Println(h.Explain(rank))
+		}
 	}
+	return total
+}
 
-	scanner := scanner.NewScanner[Hand](parser, puzzleFile)
+// solve ranks the hands, part one and two only differ in the rules they're ranked by.
+func solve(puzzleFile string, part string, rules *Rules, explain bool) {
+	slog.Info("Day seven part "+part, "puzzle file", puzzleFile, "rules", rules)
+	ordered := rankHands(puzzleFile, rules)
 
-	handHeap := &HandHeap{}
-	for scanner.Scan() {
-		hand := scanner.Struct()
-		hand.AllowsJokers = true
-		heap.Push(handHeap, hand)
+	winnings := totalWinnings(ordered, explain)
+
+	slog.Debug("finished computing!", "ordered hands", ordered)
+	slog.Info("Day seven part "+part, "total winnings", winnings)
+}
+
+// rulesFromFlags picks the named rule set, defaulting by part, then applies any overrides.
+func rulesFromFlags(cmd *cobra.Command) (*Rules, error) {
+	name := "standard"
+	if cmd.Flag("part-two").Changed {
+		name = "jokers"
+	}
+	if cmd.Flag("rules").Changed {
+		name, _ = cmd.Flags().GetString("rules")
+	}
+	base, ok := RuleSets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown rule set %q", name)
 	}
+	rules := *base
 
-	ordered := []*Hand{}
-	totalWinnings := 0
-	rank := 0
-	for handHeap.Len() > 0 {
-		rank++
-		h := heap.Pop(handHeap).(*Hand)
-		totalWinnings += h.Bid * rank
-		ordered = append(ordered, h)
+	if cmd.Flag("card-order").Changed {
+		rules.CardOrder, _ = cmd.Flags().GetString("card-order")
+	}
+	if cmd.Flag("wildcards").Changed {
+		rules.Wildcards, _ = cmd.Flags().GetString("wildcards")
+	}
+	if cmd.Flag("tie-break").Changed {
+		tieBreak, _ := cmd.Flags().GetString("tie-break")
+		t, err := ParseTieBreak(tieBreak)
+		if err != nil {
+			return nil, err
+		}
+		rules.TieBreak = t
 	}
 
-	slog.Debug("finished computing!", "ordered hands", ordered)
-	slog.Info("Day seven part one", "total winnings", totalWinnings)
+	return &rules, rules.Validate()
 }
 
 var Cmd = &cobra.Command{
 	Use: "daySeven",
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
+		explain, _ := cmd.Flags().GetBool("explain")
+		rules, err := rulesFromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
 		if !cmd.Flag("part-two").Changed {
-			partOne(puzzleInput)
+			solve(puzzleInput, "one", rules, explain)
 		} else {
-			partTwo(puzzleInput)
+			solve(puzzleInput, "two", rules, explain)
 		}
 	},
 }
 
 func init() {
 	Cmd.Flags().BoolP("part-two", "p", false, "Whether to run part two of the day's challenge")
+	Cmd.Flags().String("rules", "standard", "Rule set to score hands with (standard, jokers, poker), defaults to jokers for part two")
+	Cmd.Flags().String("card-order", "", "Override the rule set's card ranks, weakest first")
+	Cmd.Flags().String("wildcards", "", "Override the rule set's wildcard ranks")
+	Cmd.Flags().String("tie-break", "", "Override the rule set's tie break (in-order, reversed, grouped)")
+	Cmd.Flags().Bool("explain", false, "Print why each hand got its kind and rank")
 }
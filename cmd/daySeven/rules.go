@@ -0,0 +1,227 @@
+package daySeven
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type TieBreak int
+
+const (
+	// InOrder compares cards left to right as dealt
+	InOrder TieBreak = iota
+	// Reversed compares cards right to left
+	Reversed
+	// Grouped compares the ranks of the largest groups first then the kickers, like poker
+	Grouped
+)
+
+func (t TieBreak) String() string {
+	switch t {
+	case InOrder:
+		return "in-order"
+	case Reversed:
+		return "reversed"
+	case Grouped:
+		return "grouped"
+	default:
+		return ""
+	}
+}
+
+func ParseTieBreak(s string) (TieBreak, error) {
+	for _, t := range []TieBreak{InOrder, Reversed, Grouped} {
+		if t.String() == s {
+			return t, nil
+		}
+	}
+	return InOrder, fmt.Errorf("unknown tie break %q", s)
+}
+
+// Rules decide how a hand is scored: what each card is worth, which cards are wild
+// and how hands of the same kind are ordered.
+type Rules struct {
+	Name string
+	// CardOrder lists every rank, weakest first
+	CardOrder string
+	// Wildcards are ranks that count as whatever makes the best hand
+	Wildcards string
+	TieBreak  TieBreak
+}
+
+var RuleSets = map[string]*Rules{
+	"standard": {
+		Name:      "standard",
+		CardOrder: "23456789TJQKA",
+	},
+	// Jokers are now wild
+	"jokers": {
+		Name:      "jokers",
+		CardOrder: "J23456789TQKA",
+		Wildcards: "J",
+	},
+	"poker": {
+		Name:      "poker",
+		CardOrder: "23456789TJQKA",
+		TieBreak:  Grouped,
+	},
+}
+
+func (r *Rules) String() string {
+	return fmt.Sprintf("Rules{%s, order: %s, wildcards: %q, tie break: %s}", r.Name, r.CardOrder, r.Wildcards, r.TieBreak)
+}
+
+// Validate makes sure every wildcard is also a ranked card.
+func (r *Rules) Validate() error {
+	for _, w := range r.Wildcards {
+		if !strings.ContainsRune(r.CardOrder, w) {
+			return fmt.Errorf("wildcard %q is not in the card order %q", w, r.CardOrder)
+		}
+	}
+	return nil
+}
+
+// CardsPattern is the lexer pattern for a hand of five of these cards. QuoteMeta doesn't
+// escape what is special inside a character class, like -, so those are escaped here.
+func (r *Rules) CardsPattern() string {
+	class := strings.Builder{}
+	for _, card := range r.CardOrder {
+		if strings.ContainsRune(`\-^[]`, card) {
+			class.WriteByte('\\')
+		}
+		class.WriteRune(card)
+	}
+	return "[" + class.String() + "]{5}"
+}
+
+func (r *Rules) Strength(card byte) int {
+	return strings.IndexByte(r.CardOrder, card) + 1
+}
+
+func (r *Rules) IsWild(card rune) bool {
+	return strings.ContainsRune(r.Wildcards, card)
+}
+
+// Kind scores the cards, returning the group sizes it was decided on and how many wildcards
+// went into the largest group.
+func (r *Rules) Kind(cards string) (Kind, []int, int) {
+	cardCounts := map[rune]int{}
+	wilds := 0
+	for _, card := range cards {
+		if r.IsWild(card) {
+			wilds++
+			continue
+		}
+		cardCounts[card]++
+	}
+
+	counts := []int{}
+	for _, count := range cardCounts {
+		counts = append(counts, count)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(counts)))
+
+	// We can use _any_ wildcards to increase the highest count so far
+	// By increasing our best count so far, we increase our hand value
+	if len(counts) == 0 {
+		counts = []int{0}
+	}
+	counts[0] += wilds
+
+	return kindOfCounts(counts), counts, wilds
+}
+
+func kindOfCounts(counts []int) Kind {
+	second := 0
+	if len(counts) > 1 {
+		second = counts[1]
+	}
+	switch {
+	case counts[0] >= 5:
+		return FiveOfAKind
+	case counts[0] == 4:
+		return FourOfAKind
+	case counts[0] == 3 && second == 2:
+		return FullHouse
+	case counts[0] == 3:
+		return ThreeOfAKind
+	case counts[0] == 2 && second == 2:
+		return TwoPair
+	case counts[0] == 2:
+		return OnePair
+	default:
+		return HighCard
+	}
+}
+
+// tieBreakStrengths lists card strengths in the order the tie break compares them.
+func (r *Rules) tieBreakStrengths(cards string) []int {
+	strengths := make([]int, len(cards))
+	for i := range cards {
+		strengths[i] = r.Strength(cards[i])
+	}
+	switch r.TieBreak {
+	case Reversed:
+		slices.Reverse(strengths)
+	case Grouped:
+		return r.groupedStrengths(cards)
+	}
+	return strengths
+}
+
+// groupedStrengths orders card strengths the way poker compares hands of the same kind: the
+// largest groups first, the higher rank first between groups of the same size, then the
+// kickers. Wildcards join the largest group, as they do when scoring the kind.
+func (r *Rules) groupedStrengths(cards string) []int {
+	counts := map[int]int{}
+	wilds := 0
+	for i := range cards {
+		if r.IsWild(rune(cards[i])) {
+			wilds++
+			continue
+		}
+		counts[r.Strength(cards[i])]++
+	}
+
+	ranks := []int{}
+	for strength := range counts {
+		ranks = append(ranks, strength)
+	}
+	slices.SortFunc(ranks, func(a, b int) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return b - a
+	})
+	if len(ranks) == 0 {
+		// every card is wild, so they're all the strongest rank
+		ranks = []int{len(r.CardOrder)}
+	}
+	counts[ranks[0]] += wilds
+
+	strengths := []int{}
+	for _, strength := range ranks {
+		for i := 0; i < counts[strength]; i++ {
+			strengths = append(strengths, strength)
+		}
+	}
+	return strengths
+}
+
+// Less reports whether hand a ranks below hand b.
+func (r *Rules) Less(a, b *Hand) bool {
+	if a.Kind() != b.Kind() {
+		// kind values are in reverse order
+		return a.Kind() > b.Kind()
+	}
+	aStrengths := r.tieBreakStrengths(a.Cards)
+	bStrengths := r.tieBreakStrengths(b.Cards)
+	for i := range aStrengths {
+		if aStrengths[i] != bStrengths[i] {
+			return aStrengths[i] < bStrengths[i]
+		}
+	}
+	return false
+}
@@ -1,12 +1,15 @@
 package daySeventeen
 
 import (
+	"adventofcode/cmd/artifacts"
+	"adventofcode/cmd/coordinates"
 	"adventofcode/cmd/fileReader"
+	"adventofcode/cmd/render"
 	"container/heap"
 	"fmt"
+	"log"
 	"log/slog"
 	"math"
-	"os"
 	"slices"
 	"strconv"
 	"strings"
@@ -94,11 +97,29 @@ func (c *Cell) Next(dir *Direction, dest *Coordinate, grid [][]int) (*Cell, erro
 	}, nil
 }
 
-func (c *Cell) CellState() string {
-	return fmt.Sprintf("Cell{%s, %s, %d}", c.coords.String(), c.dir, c.steps)
+// CellState is everything that makes two cells interchangeable to the search.
+type CellState struct {
+	Row    int32
+	Col    int32
+	DirRow int8
+	DirCol int8
+	Steps  int16
 }
 
-func ReconstructPath(cameFrom map[string]*Cell, current *Cell) []*Cell {
+func (s CellState) String() string {
+	return fmt.Sprintf("Cell{(%d,%d), (%d,%d), %d}", s.Row, s.Col, s.DirRow, s.DirCol, s.Steps)
+}
+
+func (c *Cell) CellState() CellState {
+	state := CellState{Row: int32(c.coords.Row), Col: int32(c.coords.Col), Steps: int16(c.steps)}
+	if c.dir != nil {
+		state.DirRow = int8(c.dir.Row)
+		state.DirCol = int8(c.dir.Col)
+	}
+	return state
+}
+
+func ReconstructPath(cameFrom map[CellState]*Cell, current *Cell) []*Cell {
 	path := []*Cell{current}
 	for {
 		if prev, ok := cameFrom[current.CellState()]; ok {
@@ -120,22 +141,22 @@ func HeatLoss(row, col int, grid []string) int {
 	return h
 }
 
+// unvisited marks blocks with no g score yet. int(math.Inf(1)) isn't portable, on amd64 it
+// comes out as the smallest int so nothing ever beat it.
+const unvisited = math.MaxInt
+
 // An A* implementation!
-func AStarSearch(grid [][]int,
-	src, dest *Coordinate,
-	dirsFunc func(c *Cell) []*Direction,
-	finished func(c *Cell, d *Coordinate) bool,
-) ([]*Cell, int, [][]int) {
+func AStarSearch(grid [][]int, src, dest *Coordinate, rules Rules) ([]*Cell, int, [][]int) {
 	// Initialize the closed list (visited cells)
-	seen := map[string]*Cell{}
+	seen := map[CellState]*Cell{}
 	// Track the best paths
-	cameFrom := map[string]*Cell{}
+	cameFrom := map[CellState]*Cell{}
 
 	gScore := make([][]int, len(grid))
 	for i := 0; i < len(grid); i++ {
 		gScore[i] = make([]int, len(grid[0]))
 		for j := 0; j < len(grid[0]); j++ {
-			gScore[i][j] = int(math.Inf(1))
+			gScore[i][j] = unvisited
 		}
 	}
 	gScore[src.Row][src.Col] = 0
@@ -149,14 +170,14 @@ func AStarSearch(grid [][]int,
 	for len(*openSet) > 0 {
 		current := heap.Pop(openSet).(*Cell)
 
-		if finished(current, dest) {
+		if rules.Finished(current, dest) {
 			return ReconstructPath(cameFrom, current), current.f, gScore
 		}
 
 		slog.Debug("popped!", "cell", current, "open list len", len(*openSet))
 
 		// For each direction, check the successors
-		for _, dir := range dirsFunc(current) {
+		for _, dir := range rules.Directions(current) {
 			neighbor, err := current.Next(dir, dest, grid)
 			if err != nil {
 				slog.Debug("invalid state", "cell", current, "dir", dir, "error", err)
@@ -191,19 +212,19 @@ func PrintPath(path []*Cell, grid []string) {
 		}
 		grid[row] = grid[row][:col] + dir + grid[row][col+1:]
 	}
-	os.WriteFile("/tmp/grid.txt", []byte(strings.Join(grid, "\n")), 0644)
+	artifacts.Write("grid.txt", []byte(strings.Join(grid, "\n")))
 	niceCellPath := []string{}
 	for _, c := range path {
-		niceCellPath = append(niceCellPath, c.CellState())
+		niceCellPath = append(niceCellPath, c.CellState().String())
 	}
-	os.WriteFile("/tmp/path.txt", []byte(strings.Join(niceCellPath, "\n")), 0644)
+	artifacts.Write("path.txt", []byte(strings.Join(niceCellPath, "\n")))
 }
 
 func PrintCellDetails(cellDetails [][]int) {
 	out := ""
 	for _, row := range cellDetails {
 		for _, cell := range row {
-			if cell == int(math.Inf(1)) {
+			if cell == unvisited {
 				out += "---- "
 				continue
 			}
@@ -212,54 +233,39 @@ func PrintCellDetails(cellDetails [][]int) {
 		}
 		out += "\n"
 	}
-	os.WriteFile("/tmp/cells.txt", []byte(out), 0644)
+	artifacts.Write("cells.txt", []byte(out))
 }
 
-func Directions(c *Cell) []*Direction {
-	if c.dir == nil {
-		// Start point, only left and "down" are valid
-		return []*Direction{
-			{0, 1},
-			{1, 0},
-		}
-	}
-	dirs := []*Direction{
-		// Nifty transform for turns
-		{-c.dir.Col, c.dir.Row},
-		{c.dir.Col, -c.dir.Row},
+// renderSearch draws the heat lost reaching each block with the best path over it.
+func renderSearch(path []*Cell, rows []string, gScore [][]int, request *render.Request) {
+	cells := render.Path{}
+	for _, c := range path {
+		cells = append(cells, coordinates.Coordinate{Row: c.coords.Row, Col: c.coords.Col})
 	}
-	if c.steps < 3 {
-		dirs = append(dirs, c.dir)
+	heat := render.Heatmap{Values: gScore, Unset: unvisited}
+	if err := request.Write(rows, heat, cells); err != nil {
+		log.Fatal(err)
 	}
-	return dirs
 }
 
-func partOne(puzzleFile string) {
-	slog.Info("Day Seventeen part one", "puzzle file", puzzleFile)
-	rows := strings.Split(fileReader.ReadFileContents(puzzleFile), "\n")
-	grid := make([][]int, len(rows))
-	for i, row := range rows {
-		grid[i] = make([]int, len(row))
-		for j, cell := range row {
-			h, _ := strconv.Atoi(string(cell))
-			grid[i][j] = h
-		}
-	}
-
-	src := &Coordinate{0, 0}
-	dest := &Coordinate{len(rows) - 1, len(rows[0]) - 1}
-
-	path, heatLoss, gScore := AStarSearch(grid, src, dest, Directions, func(c *Cell, d *Coordinate) bool {
-		return c.coords.Equals(d)
-	})
-
-	PrintPath(path, rows)
-	PrintCellDetails(gScore)
-
-	slog.Info("The path from source to destination found", "path", path, "heat loss", heatLoss)
+// Rules describe how a crucible is allowed to move.
+type Rules struct {
+	// MinRun is how many blocks it must go straight before turning or stopping
+	MinRun int
+	// MaxRun is how many blocks it can go straight before it has to turn
+	MaxRun int
+	// AllowReverse lets it turn all the way around once it could turn
+	AllowReverse bool
+	// StopAnytime lets it stop at the destination before finishing MinRun
+	StopAnytime bool
 }
 
-func UltraDirections(c *Cell) []*Direction {
+var (
+	CrucibleRules      = Rules{MinRun: 1, MaxRun: 3}
+	UltraCrucibleRules = Rules{MinRun: 4, MaxRun: 10}
+)
+
+func (r Rules) Directions(c *Cell) []*Direction {
 	if c.dir == nil {
 		// Start point, only left and "down" are valid
 		return []*Direction{
@@ -274,7 +280,7 @@ func UltraDirections(c *Cell) []*Direction {
 	However, it will eventually start to get wobbly: an ultra crucible can move a maximum of
 	ten consecutive blocks without turning.
 	**/
-	if c.steps < 4 {
+	if c.steps < r.MinRun {
 		return []*Direction{c.dir}
 	}
 
@@ -283,18 +289,20 @@ func UltraDirections(c *Cell) []*Direction {
 		{-c.dir.Col, c.dir.Row},
 		{c.dir.Col, -c.dir.Row},
 	}
-	if c.steps < 10 {
+	if r.AllowReverse {
+		dirs = append(dirs, &Direction{-c.dir.Row, -c.dir.Col})
+	}
+	if c.steps < r.MaxRun {
 		dirs = append(dirs, c.dir)
 	}
 	return dirs
 }
 
-func UltraFinished(c *Cell, d *Coordinate) bool {
-	return c.coords.Equals(d) && c.steps >= 4
+func (r Rules) Finished(c *Cell, d *Coordinate) bool {
+	return c.coords.Equals(d) && (r.StopAnytime || c.steps >= r.MinRun)
 }
 
-func partTwo(puzzleFile string) {
-	slog.Info("Day Seventeen part two", "puzzle file", puzzleFile)
+func parseGrid(puzzleFile string) ([]string, [][]int) {
 	rows := strings.Split(fileReader.ReadFileContents(puzzleFile), "\n")
 	grid := make([][]int, len(rows))
 	for i, row := range rows {
@@ -304,12 +312,21 @@ func partTwo(puzzleFile string) {
 			grid[i][j] = h
 		}
 	}
+	return rows, grid
+}
+
+// solve finds the least heat lost getting the crucible across, part one and two only differ
+// in the rules it moves by.
+func solve(puzzleFile string, part string, rules Rules, request *render.Request) {
+	slog.Info("Day Seventeen part "+part, "puzzle file", puzzleFile, "rules", rules)
+	rows, grid := parseGrid(puzzleFile)
 
 	src := &Coordinate{0, 0}
 	dest := &Coordinate{len(rows) - 1, len(rows[0]) - 1}
 
-	path, heatLoss, gScore := AStarSearch(grid, src, dest, UltraDirections, UltraFinished)
+	path, heatLoss, gScore := AStarSearch(grid, src, dest, rules)
 
+	renderSearch(path, rows, gScore, request)
 	PrintPath(path, rows)
 	PrintCellDetails(gScore)
 
@@ -320,14 +337,35 @@ var Cmd = &cobra.Command{
 	Use: "daySeventeen",
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
+		rules := CrucibleRules
+		if cmd.Flag("part-two").Changed {
+			rules = UltraCrucibleRules
+		}
+		if cmd.Flag("min-run").Changed {
+			rules.MinRun, _ = cmd.Flags().GetInt("min-run")
+		}
+		if cmd.Flag("max-run").Changed {
+			rules.MaxRun, _ = cmd.Flags().GetInt("max-run")
+		}
+		rules.AllowReverse, _ = cmd.Flags().GetBool("allow-reverse")
+		rules.StopAnytime, _ = cmd.Flags().GetBool("stop-anytime")
+		request, err := render.FromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
+
 		if !cmd.Flag("part-two").Changed {
-			partOne(puzzleInput)
+			solve(puzzleInput, "one", rules, request)
 		} else {
-			partTwo(puzzleInput)
+			solve(puzzleInput, "two", rules, request)
 		}
 	},
 }
 
 func init() {
 	Cmd.Flags().Bool("part-two", false, "Whether to run part two of the day's challenge")
+	Cmd.Flags().Int("min-run", 1, "Blocks the crucible must go straight before turning, defaults to 4 for part two")
+	Cmd.Flags().Int("max-run", 3, "Blocks the crucible can go straight before turning, defaults to 10 for part two")
+	Cmd.Flags().Bool("allow-reverse", false, "Let the crucible turn around")
+	Cmd.Flags().Bool("stop-anytime", false, "Let the crucible stop before finishing its minimum run")
 }
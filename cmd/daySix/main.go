@@ -3,8 +3,8 @@ package daySix
 import (
 	"fmt"
 	"log/slog"
+	"math/big"
 	"os"
-	"strconv"
 	"strings"
 
 	"github.com/alecthomas/participle/v2"
@@ -12,20 +12,34 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// BigInt captures an integer of any size from the puzzle input.
+type BigInt struct {
+	*big.Int
+}
+
+func (b *BigInt) Capture(values []string) error {
+	i, ok := new(big.Int).SetString(values[0], 10)
+	if !ok {
+		return fmt.Errorf("invalid integer %q", values[0])
+	}
+	b.Int = i
+	return nil
+}
+
 type Input struct {
-	Times     []int `"Time" @Int+ EOL`
-	Distances []int `"Distance" @Int+`
+	Times     []BigInt `"Time" @Int+ EOL`
+	Distances []BigInt `"Distance" @Int+`
 }
 
 func parse(puzzleFile string) *Input {
 	f, err := os.ReadFile(puzzleFile)
 	if err != nil {
-		slog.Error("failed to parse", err)
+		slog.Error("failed to parse", "err", err)
 		panic(err)
 	}
 
 	inputLexer := lexer.MustSimple([]lexer.SimpleRule{
-		{"Int", `(\d*\.)?\d+`},
+		{"Int", `\d+`},
 		{"Ident", `[a-zA-Z_]\w*`},
 		{"EOL", `\n`},
 		{"Colon", `:`},
@@ -50,34 +64,59 @@ func parse(puzzleFile string) *Input {
 	return input
 }
 
-func distanceForTime(timeHeld, timeRunning int) int {
-	return timeHeld * timeRunning
+func distanceForTime(timeHeld, timeRunning *big.Int) *big.Int {
+	return new(big.Int).Mul(timeHeld, timeRunning)
 }
 
-func validHolds(times []int, distances []int) int {
-	validHolds := make(map[int]int)
+func beats(timeHeld, totalTime, bestDistance *big.Int) bool {
+	timeRunning := new(big.Int).Sub(totalTime, timeHeld)
+	return distanceForTime(timeHeld, timeRunning).Cmp(bestDistance) > 0
+}
 
-	for i, totalTime := range times {
-		bestDistance := distances[i]
-		firstGoodHold := 0
-		for timeHeld := 0; timeHeld < totalTime; timeHeld++ {
-			timeRunning := totalTime - timeHeld
-			distance := distanceForTime(timeHeld, timeRunning)
-			if distance > bestDistance {
-				firstGoodHold = timeHeld - 1
-				break
-			}
+func winningHolds(totalTime, bestDistance *big.Int) *big.Int {
+	/**
+	Holding for h out of T leaves a distance of h*(T-h), so winning holds are the integers
+	strictly between the roots of h^2 - T*h + D = 0, (T ± sqrt(T^2 - 4D)) / 2. The integer
+	square root gets us within one of the lower root, we nudge it onto the first winning hold
+	and lean on the curve's symmetry for the last one.
+	**/
+	one := big.NewInt(1)
+	discriminant := new(big.Int).Mul(totalTime, totalTime)
+	discriminant.Sub(discriminant, new(big.Int).Lsh(bestDistance, 2))
+	if discriminant.Sign() <= 0 {
+		// the best we can do only ties the record, at most
+		return new(big.Int)
+	}
+
+	firstGoodHold := new(big.Int).Sub(totalTime, new(big.Int).Sqrt(discriminant))
+	firstGoodHold.Rsh(firstGoodHold, 1)
+	for firstGoodHold.Sign() > 0 && beats(new(big.Int).Sub(firstGoodHold, one), totalTime, bestDistance) {
+		firstGoodHold.Sub(firstGoodHold, one)
+	}
+	// holding for half the race goes furthest, if that doesn't win nothing does. The roots
+	// can be apart without a whole hold between them, like T=3 and D=2
+	half := new(big.Int).Rsh(totalTime, 1)
+	for !beats(firstGoodHold, totalTime, bestDistance) {
+		if firstGoodHold.Cmp(half) >= 0 {
+			return new(big.Int)
 		}
-		// The times held that don't work are symmetric on either side of a curve of good times
-		validHolds[i] = totalTime - (firstGoodHold * 2) - 1
+		firstGoodHold.Add(firstGoodHold, one)
 	}
 
-	answer := 1
-	for _, v := range validHolds {
-		answer = answer * v
+	// The times held that don't work are symmetric on either side of a curve of good times
+	holds := new(big.Int).Sub(totalTime, new(big.Int).Lsh(firstGoodHold, 1))
+	return holds.Add(holds, one)
+}
+
+func validHolds(times []BigInt, distances []BigInt) *big.Int {
+	answer := big.NewInt(1)
+	for i, totalTime := range times {
+		holds := winningHolds(totalTime.Int, distances[i].Int)
+		slog.Debug("calculated holds", "time", totalTime, "distance", distances[i], "holds", holds)
+		answer.Mul(answer, holds)
 	}
 
-	slog.Debug("calculated holds", "answer", validHolds, "answer", answer)
+	slog.Debug("calculated holds", "answer", answer)
 	return answer
 }
 
@@ -87,19 +126,24 @@ func partOne(puzzleFile string) {
 	slog.Info("Day six part one", "input", input, "answer", answer)
 }
 
+// concat joins the digits of every number, the kerning having been bad.
+func concat(parts []BigInt) BigInt {
+	digits := []string{}
+	for _, p := range parts {
+		digits = append(digits, p.String())
+	}
+	joined := BigInt{}
+	joined.Capture([]string{strings.Join(digits, "")})
+	return joined
+}
+
 func partTwo(puzzleFile string) {
 	input := parse(puzzleFile)
-	timeParts := []string{}
-	distanceParts := []string{}
-	for i := 0; i < len(input.Times); i++ {
-		timeParts = append(timeParts, fmt.Sprint(input.Times[i]))
-		distanceParts = append(distanceParts, fmt.Sprint(input.Distances[i]))
-	}
 
-	time, _ := strconv.Atoi(strings.Join(timeParts, ""))
-	distance, _ := strconv.Atoi(strings.Join(distanceParts, ""))
+	time := concat(input.Times)
+	distance := concat(input.Distances)
 
-	answer := validHolds([]int{time}, []int{distance})
+	answer := validHolds([]BigInt{time}, []BigInt{distance})
 	slog.Info("Day six part two", "time", time, "distance", distance, "answer", answer)
 }
 
@@ -1,12 +1,17 @@
 package daySixteen
 
 import (
+	"adventofcode/cmd/coordinates"
 	"adventofcode/cmd/fileReader"
+	"adventofcode/cmd/render"
+	"adventofcode/cmd/util"
 	"fmt"
+	"log"
 	"log/slog"
 	"os"
-	"strconv"
+	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/spf13/cobra"
 )
@@ -220,70 +225,175 @@ func (b *Beam) inBounds(rows []string) bool {
 	return b.y >= 0 && b.y < len(rows) && b.x >= 0 && b.x < len(rows[0])
 }
 
-func PrintTouches(rows []string, touchedSpaces map[string]bool) {
+// DirectionIndex packs the beam's direction into 0-3 for the beam state bitset.
+func (b *Beam) DirectionIndex() int {
+	switch {
+	case b.deltaX == 1:
+		return 0
+	case b.deltaX == -1:
+		return 1
+	case b.deltaY == 1:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// bitset is a fixed size set of small integers, one bit each.
+type bitset []uint64
+
+func newBitset(size int) bitset {
+	return make(bitset, (size+63)/64)
+}
+
+// Set adds i and reports whether it was missing before.
+func (s bitset) Set(i int) bool {
+	word, bit := i/64, uint64(1)<<(i%64)
+	if s[word]&bit != 0 {
+		return false
+	}
+	s[word] |= bit
+	return true
+}
+
+func (s bitset) Has(i int) bool {
+	return s[i/64]&(uint64(1)<<(i%64)) != 0
+}
+
+// Energy tracks which tiles a beam has crossed and which position and direction
+// pairs have already been stepped, so every beam eventually dies out.
+type Energy struct {
+	width     int
+	touched   bitset
+	seenBeams bitset
+	energized int
+}
+
+func NewEnergy(rows []string) *Energy {
+	tiles := len(rows) * len(rows[0])
+	return &Energy{
+		width:     len(rows[0]),
+		touched:   newBitset(tiles),
+		seenBeams: newBitset(tiles * 4),
+	}
+}
+
+// Visit records the beam, returning false when the same beam state was seen before.
+func (e *Energy) Visit(b *Beam) bool {
+	tile := b.y*e.width + b.x
+	if !e.seenBeams.Set(tile*4 + b.DirectionIndex()) {
+		return false
+	}
+	if e.touched.Set(tile) {
+		e.energized++
+	}
+	return true
+}
+
+func (e *Energy) Touched(x, y int) bool {
+	return e.touched.Has(y*e.width + x)
+}
+
+func PrintTouches(rows []string, energy *Energy) {
 	if strings.ToLower(os.Getenv("LOG_LEVEL")) != "debug" {
 		return
 	}
 	fmt.Println("##### touches ######")
-	for ts := range touchedSpaces {
-		split := strings.Split(ts, ",")
-		x, _ := strconv.Atoi(split[0])
-		y, _ := strconv.Atoi(split[1])
+	for y, row := range rows {
+		line := []byte(row)
+		for x := range line {
+			if energy.Touched(x, y) {
+				line[x] = '#'
+			}
+		}
+		fmt.Println(string(line))
+	}
+}
 
-		rows[y] = rows[y][:x] + "#" + rows[y][x+1:]
+func touchedCells(rows []string, energy *Energy) render.Visited {
+	touched := render.Visited{}
+	for y, row := range rows {
+		for x := range row {
+			if energy.Touched(x, y) {
+				touched = append(touched, coordinates.Coordinate{Row: y, Col: x})
+			}
+		}
 	}
+	return touched
+}
 
-	for _, row := range rows {
-		fmt.Println(row)
+// renderTouches draws the grid with every energized tile shaded.
+func renderTouches(rows []string, energy *Energy, request *render.Request) {
+	if err := request.Write(rows, touchedCells(rows, energy)); err != nil {
+		log.Fatal(err)
 	}
 }
 
-func calculateEnergy(rows []string, startBeam *Beam, maxIterations int) int {
-	touchedSpaces := map[string]bool{}
+// recordBeams adds a frame of the energized tiles with the beams still moving on top.
+func recordBeams(rows []string, energy *Energy, beams []*Beam, recorder *render.Recorder) {
+	if recorder == nil {
+		return
+	}
+	heads := render.Points{}
+	for _, b := range beams {
+		heads = append(heads, coordinates.Coordinate{Row: b.y, Col: b.x})
+	}
+	recorder.Frame(rows, touchedCells(rows, energy), heads)
+}
+
+// Energize runs the start beam until every beam leaves the grid or repeats a state.
+func Energize(rows []string, startBeam *Beam, recorder *render.Recorder) *Energy {
+	energy := NewEnergy(rows)
+	energy.Visit(startBeam)
 	beams := []*Beam{startBeam}
-	seenBeams := map[string]bool{} // ???: should I memoize this across runs?
 
 	steps := 0
 	for len(beams) > 0 {
-		//slog.Debug("running beams", "beams", beams)
+		recordBeams(rows, energy, beams, recorder)
 		nextBeams := []*Beam{}
-
 		for _, beam := range beams {
-			touchedSpaces[beam.LocationString()] = true
-			news := beam.StepBeam(rows)
-			for _, newBeam := range news {
-				if _, ok := seenBeams[newBeam.Hash()]; ok {
-					continue
+			for _, newBeam := range beam.StepBeam(rows) {
+				if energy.Visit(newBeam) {
+					nextBeams = append(nextBeams, newBeam)
 				}
-				seenBeams[newBeam.Hash()] = true
-				nextBeams = append(nextBeams, newBeam)
 			}
 		}
 
 		beams = nextBeams
-		if steps > maxIterations {
-			slog.Debug("breaking because max iterations", "start", startBeam, "steps", steps, "maxIterations", maxIterations)
-			break
-		}
 		steps++
 	}
 
-	//PrintTouches(rows, touchedSpaces)
-	slog.Debug("calculated energy", "start", startBeam, "touched spaces", touchedSpaces, "steps", steps, "energized spaces", len(touchedSpaces))
-	return len(touchedSpaces)
+	slog.Debug("calculated energy", "start", startBeam, "steps", steps, "energized spaces", energy.energized)
+	return energy
 }
 
-func partOne(puzzleFile string, maxIterations int) {
+func calculateEnergy(rows []string, startBeam *Beam) int {
+	return Energize(rows, startBeam, nil).energized
+}
+
+func partOne(puzzleFile string, request *render.Request, recorder *render.Recorder) {
 	slog.Info("Day Sixteen part one", "puzzle file", puzzleFile)
 	rows := strings.Split(fileReader.ReadFileContents(puzzleFile), "\n")
 
-	energizedSpaces := calculateEnergy(rows, &Beam{0, 0, 0, 1, 0}, maxIterations)
+	energy := Energize(rows, &Beam{0, 0, 0, 1, 0}, recorder)
+	energizedSpaces := energy.energized
+	PrintTouches(rows, energy)
+	renderTouches(rows, energy, request)
+	recordBeams(rows, energy, nil, recorder)
+	if err := recorder.Save(); err != nil {
+		log.Fatal(err)
+	}
 
 	slog.Info("Day Sixteen part one", "energized spaces", energizedSpaces)
 }
 
-func partTwo(puzzleFile string, maxIterations int) {
-	slog.Info("Day Sixteen part two", "puzzle file", puzzleFile)
+type startResult struct {
+	start           *Beam
+	energizedSpaces int
+}
+
+func partTwo(puzzleFile string, workers int, request *render.Request, recorder *render.Recorder) {
+	slog.Info("Day Sixteen part two", "puzzle file", puzzleFile, "workers", workers)
 	rows := strings.Split(fileReader.ReadFileContents(puzzleFile), "\n")
 
 	validStarts := []*Beam{}
@@ -299,35 +409,74 @@ func partTwo(puzzleFile string, maxIterations int) {
 		// right
 		validStarts = append(validStarts, &Beam{0, len(rows[0]) - 1, y, -1, 0})
 	}
-	//validStarts = []*Beam{{0, 0, 8, 1, 0}}
 	slog.Debug("all starts", "validStarts", validStarts)
 
-	max := 0
-	for _, start := range validStarts {
-		energizedSpaces := calculateEnergy(rows, start, maxIterations)
-		if energizedSpaces > max {
-			max = energizedSpaces
+	starts := make(chan *Beam)
+	results := make(chan startResult)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range starts {
+				results <- startResult{start, calculateEnergy(rows, start)}
+			}
+		}()
+	}
+	go func() {
+		for _, start := range validStarts {
+			starts <- start
+		}
+		close(starts)
+		wg.Wait()
+		close(results)
+	}()
+
+	best := startResult{}
+	for result := range results {
+		if best.start == nil || result.energizedSpaces > best.energizedSpaces {
+			best = result
 		}
 	}
 
-	// need to generate all valid starts and then iterate
-	slog.Info("Day Sixteen part two", "max energized spaces", max)
+	if util.InDebugMode() || request != nil || recorder != nil {
+		// replay the best start, this time watching it
+		energy := Energize(rows, best.start, recorder)
+		PrintTouches(rows, energy)
+		renderTouches(rows, energy, request)
+		recordBeams(rows, energy, nil, recorder)
+		if err := recorder.Save(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	slog.Info("Day Sixteen part two", "max energized spaces", best.energizedSpaces, "start", best.start)
 }
 
 var Cmd = &cobra.Command{
 	Use: "daySixteen",
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
-		maxIterations, _ := cmd.Flags().GetInt("max-iterations")
+		request, err := render.FromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
+		recorder, err := render.RecorderFromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
 		if !cmd.Flag("part-two").Changed {
-			partOne(puzzleInput, maxIterations)
+			partOne(puzzleInput, request, recorder)
 		} else {
-			partTwo(puzzleInput, maxIterations)
+			workers, _ := cmd.Flags().GetInt("workers")
+			if workers < 1 {
+				log.Fatalf("workers must be at least 1, got %d", workers)
+			}
+			partTwo(puzzleInput, workers, request, recorder)
 		}
 	},
 }
 
 func init() {
 	Cmd.Flags().Bool("part-two", false, "Whether to run part two of the day's challenge")
-	Cmd.Flags().Int("max-iterations", 100, "Max iterations to go through")
+	Cmd.Flags().Int("workers", runtime.NumCPU(), "Starts to simulate at once, only applicable for part two")
 }
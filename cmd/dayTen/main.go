@@ -1,11 +1,15 @@
 package dayTen
 
 import (
+	"adventofcode/cmd/artifacts"
+	"adventofcode/cmd/coordinates"
+	"adventofcode/cmd/render"
 	"bufio"
 	"fmt"
 	"log"
 	"log/slog"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -110,11 +114,11 @@ func (g *Grid) PartTwoString() string {
 	str := ""
 	for _, row := range g.rows {
 		for _, p := range row {
-			if p.IsTrappedPosition() {
+			if p.Inside {
 				str += "I "
 			} else if p.IsPath() {
 				str += p.Type.String() + " "
-			} else if p.IsEdgePosition() {
+			} else {
 				str += "O "
 			}
 		}
@@ -128,6 +132,8 @@ type Position struct {
 	Type              Pipe
 	DistanceFromStart int
 	TouchesEdge       bool
+	// Inside is set by the ray count for tiles the loop encloses
+	Inside bool
 }
 
 func (p *Position) String() string {
@@ -275,73 +281,148 @@ func (g *Grid) GetStart() *Position {
 	return g.Get(g.StartX, g.StartY)
 }
 
-func calculateDistance(g *Grid, p *Position, distanceFromStart int) int {
-	// depth first traversal of each child node until we find start, keep track of distance so far
-	slog.Debug("Calculating distance", "p", p, "distanceFromStart", distanceFromStart)
-	if p.DistanceFromStart != -1 {
-		return p.DistanceFromStart
+// InferStart swaps the start tile for the pipe joining the two neighbours that connect to it.
+func (g *Grid) InferStart() (Pipe, error) {
+	x, y := g.StartX, g.StartY
+	north := CanSouth(g.Get(x, y-1))
+	south := CanNorth(g.Get(x, y+1))
+	east := CanWest(g.Get(x+1, y))
+	west := CanEast(g.Get(x-1, y))
+
+	var pipe Pipe
+	switch {
+	case north && south && !east && !west:
+		pipe = Vertical
+	case east && west && !north && !south:
+		pipe = Horizontal
+	case north && east && !south && !west:
+		pipe = NinetyDegreeNorthEast
+	case north && west && !south && !east:
+		pipe = NinetyDegreeNorthWest
+	case south && west && !north && !east:
+		pipe = NinetyDegreeSouthWest
+	case south && east && !north && !west:
+		pipe = NinetyDegreeSouthEast
+	default:
+		return Start, fmt.Errorf("start at (%d,%d) needs exactly two connecting neighbours, north %t south %t east %t west %t",
+			x, y, north, south, east, west)
 	}
 
-	// Start with our best guess, based on parent suggested distance
-	p.DistanceFromStart = distanceFromStart
-
-	// Fetch the distances for any valid neighbor
-	neighborDistances := []int{}
-	for _, conn := range p.Connections(g) {
-		neighborDistances = append(neighborDistances, calculateDistance(g, conn, distanceFromStart+1))
-	}
+	g.GetStart().Type = pipe
+	return pipe, nil
+}
 
-	// Check if our current (parent suggested) distance can be beat by traversing through
-	// a neighbor
-	for _, d := range neighborDistances {
-		if d < p.DistanceFromStart {
-			p.DistanceFromStart = d + 1
+// Loop walks the main loop from the start, returning its tiles in order. Every tile on it
+// has its distance from the start set, whichever way round is shorter.
+func (g *Grid) Loop() ([]*Position, error) {
+	start := g.GetStart()
+	loop := []*Position{start}
+	var prev *Position
+	for p := start; ; {
+		connections := p.Connections(g)
+		if len(connections) != 2 {
+			return nil, fmt.Errorf("loop is broken at %s", p)
 		}
+		next := connections[0]
+		if next == prev {
+			next = connections[1]
+		}
+		if next == start {
+			break
+		}
+		loop = append(loop, next)
+		prev, p = p, next
 	}
 
-	if g.MaxDistance < p.DistanceFromStart {
-		g.MaxDistance = p.DistanceFromStart
-		g.MaxX = p.X
-		g.MaxY = p.Y
+	for i, p := range loop {
+		p.DistanceFromStart = min(i, len(loop)-i)
 	}
+	farthest := loop[len(loop)/2]
+	g.MaxDistance, g.MaxX, g.MaxY = farthest.DistanceFromStart, farthest.X, farthest.Y
 
-	return p.DistanceFromStart
+	return loop, nil
 }
 
-func (g *Grid) ExpandGroundPositions() {
-	// start with all ground edges and expand until hitting another ground edge, a path pipe, or the edge
-	// of the grid
-	toVisit := []*Position{}
-	for _, row := range g.rows {
-		for _, p := range row {
-			if p.IsEdgePosition() {
-				toVisit = append(toVisit, p)
-			}
+func (p *Position) IsCorner() bool {
+	return p.Type != Vertical && p.Type != Horizontal
+}
+
+// Corners are the vertices of the polygon the loop traces, the straight pipes between
+// them add nothing to its shape.
+func Corners(loop []*Position) []*Position {
+	corners := []*Position{}
+	for _, p := range loop {
+		if p.IsCorner() {
+			corners = append(corners, p)
 		}
 	}
+	return corners
+}
+
+// CountEnclosedByArea takes the loop's area from the
+// [Shoelace Formula](https://en.wikipedia.org/wiki/Shoelace_formula) and subtracts the tiles
+// on the loop using [Pick's theorem](https://en.wikipedia.org/wiki/Pick%27s_theorem).
+func CountEnclosedByArea(loop []*Position) int {
+	corners := Corners(loop)
+	twiceArea := 0
+	for i, c1 := range corners {
+		c2 := corners[(i+1)%len(corners)]
+		twiceArea += c1.X*c2.Y - c2.X*c1.Y
+	}
+	if twiceArea < 0 {
+		twiceArea = -twiceArea
+	}
+	return (twiceArea-len(loop))/2 + 1
+}
+
+// ExpandGroundPositions floods in from outside the grid and marks every tile it reaches as
+// touching the edge. The grid is doubled up first so the flood can squeeze between pipes
+// that sit side by side without joining: tile (x, y) becomes cell (2x+1, 2y+1), the cells
+// between joined loop tiles are walls and a border of empty cells surrounds it all.
+func (g *Grid) ExpandGroundPositions(loop []*Position) {
+	height, width := 2*len(g.rows)+1, 2*len(g.rows[0])+1
+	walls := make([][]bool, height)
+	for i := range walls {
+		walls[i] = make([]bool, width)
+	}
+	for i, p := range loop {
+		next := loop[(i+1)%len(loop)]
+		walls[2*p.Y+1][2*p.X+1] = true
+		walls[p.Y+next.Y+1][p.X+next.X+1] = true
+	}
 
+	reached := make([][]bool, height)
+	for i := range reached {
+		reached[i] = make([]bool, width)
+	}
+	reached[0][0] = true
+	toVisit := [][2]int{{0, 0}}
 	for len(toVisit) > 0 {
-		p := toVisit[0]
+		cell := toVisit[0]
 		toVisit = toVisit[1:]
 
 		// visit neighbors
-		for _, n := range []*Position{
-			g.Get(p.X, p.Y-1),
-			g.Get(p.X, p.Y+1),
-			g.Get(p.X-1, p.Y),
-			g.Get(p.X+1, p.Y),
+		for _, n := range [][2]int{
+			{cell[0] - 1, cell[1]},
+			{cell[0] + 1, cell[1]},
+			{cell[0], cell[1] - 1},
+			{cell[0], cell[1] + 1},
 		} {
-			if n == nil {
+			if n[0] < 0 || n[1] < 0 || n[0] >= height || n[1] >= width {
 				continue
 			}
-
-			// New path to the edge!
-			if !n.TouchesEdge && !n.IsPath() {
-				n.TouchesEdge = true
+			if !walls[n[0]][n[1]] && !reached[n[0]][n[1]] {
+				reached[n[0]][n[1]] = true
 				toVisit = append(toVisit, n)
 			}
 		}
 	}
+
+	for y, row := range g.rows {
+		for x, p := range row {
+			p.TouchesEdge = reached[2*y+1][2*x+1]
+		}
+	}
 }
 
 func (g *Grid) CountTrappedGround() int {
@@ -358,8 +439,8 @@ func (g *Grid) CountTrappedGround() int {
 }
 
 func (g *Grid) CountTrappedWithRay() int {
-	// Assume we ran flood fil first. Then we will only need to special case the trapped sections
-	// to double check they actually are.
+	// Relies on Loop having marked the path and InferStart having replaced the start tile,
+	// any pipes off the loop count as ground.
 	count := 0
 	for _, row := range g.rows {
 		wallCount := 0
@@ -375,13 +456,12 @@ func (g *Grid) CountTrappedWithRay() int {
 					wallCount++
 				case NinetyDegreeNorthWest: // J
 					wallCount++
-				case Start:
-					wallCount++
 				}
 				continue
 			}
 			if wallCount%2 == 1 {
 				slog.Debug("counting trapped position", "p", p, "wallCount", wallCount)
+				p.Inside = true
 				count++
 			}
 		}
@@ -390,52 +470,131 @@ func (g *Grid) CountTrappedWithRay() int {
 	return count
 }
 
-func partOne(puzzleFile string) {
-	// dayTen.simple.input is 4
-	// dayTen.complex.input is 8
-	slog.Info("Day Ten part one", "puzzle file", puzzleFile)
+// LoopReport sums up the shape of the main loop.
+type LoopReport struct {
+	Start    Pipe
+	Length   int
+	Farthest *Position
+	Enclosed int
+}
 
+func buildLoop(puzzleFile string) (*Grid, []*Position, *LoopReport) {
 	grid := parse(puzzleFile)
-	for _, c := range grid.GetStart().Connections(grid) {
-		calculateDistance(grid, c, 1)
+	start, err := grid.InferStart()
+	if err != nil {
+		log.Fatal(err)
+	}
+	loop, err := grid.Loop()
+	if err != nil {
+		log.Fatal(err)
 	}
-
 	slog.Debug("distance calculated", "grid", grid.String())
 
-	slog.Info("Day Ten part one", "max distance", grid.MaxDistance, "max x", grid.MaxX, "max y", grid.MaxY)
+	report := &LoopReport{
+		Start:    start,
+		Length:   len(loop),
+		Farthest: grid.Get(grid.MaxX, grid.MaxY),
+		Enclosed: CountEnclosedByArea(loop),
+	}
+	slog.Info("loop report", "start", report.Start, "length", report.Length,
+		"farthest", report.Farthest, "enclosed", report.Enclosed)
+	return grid, loop, report
 }
 
-func partTwo(puzzleFile string) {
-	slog.Info("Day Ten part two", "puzzle file", puzzleFile)
+// Rows are the grid's tiles as the puzzle draws them.
+func (g *Grid) Rows() []string {
+	rows := make([]string, len(g.rows))
+	for y, row := range g.rows {
+		b := strings.Builder{}
+		for _, p := range row {
+			b.WriteString(p.Type.String())
+		}
+		rows[y] = b.String()
+	}
+	return rows
+}
 
-	// populate all edge locations and the path with non-negative values
-	grid := parse(puzzleFile)
-	for _, c := range grid.GetStart().Connections(grid) {
-		calculateDistance(grid, c, 1)
+// renderLoop draws the loop with the tiles it encloses shaded.
+func renderLoop(grid *Grid, loop []*Position, request *render.Request) {
+	if request == nil {
+		return
+	}
+	path := render.Path{}
+	for _, p := range loop {
+		path = append(path, coordinates.Coordinate{Row: p.Y, Col: p.X})
+	}
+	// close the loop back at the start
+	path = append(path, path[0])
+
+	grid.CountTrappedWithRay()
+	inside := render.Visited{}
+	for _, row := range grid.rows {
+		for _, p := range row {
+			if p.Inside {
+				inside = append(inside, coordinates.Coordinate{Row: p.Y, Col: p.X})
+			}
+		}
+	}
+	if err := request.Write(grid.Rows(), inside, path); err != nil {
+		log.Fatal(err)
 	}
+}
 
-	//grid.ExpandGroundPositions()
-	trappedCount := grid.CountTrappedWithRay()
-	//trappedCount := grid.CountTrappedGround()
+// crossCheck counts the enclosed tiles again by flood fill and by ray casting.
+func crossCheck(grid *Grid, loop []*Position, enclosed int) {
+	grid.ExpandGroundPositions(loop)
+	flooded := grid.CountTrappedGround()
+	rayCast := grid.CountTrappedWithRay()
+	if flooded != enclosed || rayCast != enclosed {
+		slog.Warn("enclosed counts disagree", "area", enclosed, "flood fill", flooded, "ray cast", rayCast)
+		return
+	}
+	slog.Debug("enclosed counts agree", "count", enclosed)
+}
 
-	slog.Debug("distance calculated", "grid", grid.String())
+func partOne(puzzleFile string, request *render.Request) {
+	// dayTen.simple.input is 4
+	// dayTen.complex.input is 8
+	slog.Info("Day Ten part one", "puzzle file", puzzleFile)
+
+	grid, loop, _ := buildLoop(puzzleFile)
+	renderLoop(grid, loop, request)
 
-	os.WriteFile("inputs/dayTen-partTwo.txt", []byte(grid.PartTwoString()), 0644)
-	slog.Info("Day Ten part two", "trapped count", trappedCount)
+	slog.Info("Day Ten part one", "max distance", grid.MaxDistance, "max x", grid.MaxX, "max y", grid.MaxY)
+}
+
+func partTwo(puzzleFile string, check bool, request *render.Request) {
+	slog.Info("Day Ten part two", "puzzle file", puzzleFile)
+
+	grid, loop, report := buildLoop(puzzleFile)
+	if check {
+		// only the ray cast marks which tiles are inside
+		crossCheck(grid, loop, report.Enclosed)
+		artifacts.Write("enclosed.txt", []byte(grid.PartTwoString()))
+	}
+	renderLoop(grid, loop, request)
+
+	slog.Info("Day Ten part two", "trapped count", report.Enclosed)
 }
 
 var Cmd = &cobra.Command{
 	Use: "dayTen",
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
+		request, err := render.FromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
 		if !cmd.Flag("part-two").Changed {
-			partOne(puzzleInput)
+			partOne(puzzleInput, request)
 		} else {
-			partTwo(puzzleInput)
+			check, _ := cmd.Flags().GetBool("cross-check")
+			partTwo(puzzleInput, check, request)
 		}
 	},
 }
 
 func init() {
 	Cmd.Flags().Bool("part-two", false, "Whether to run part two of the day's challenge")
+	Cmd.Flags().Bool("cross-check", false, "Also count the enclosed tiles by flood fill and ray casting, only applicable for part two")
 }
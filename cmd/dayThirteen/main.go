@@ -1,14 +1,15 @@
 package dayThirteen
 
 import (
+	"adventofcode/cmd/coordinates"
+	"adventofcode/cmd/render"
 	"bufio"
 	"fmt"
 	"log"
 	"log/slog"
-	"math"
 	"math/bits"
 	"os"
-	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -18,227 +19,287 @@ import (
  011001101
 
  one shift plus half (4) means five is shift point
+
+Rows are packed into 64 bit words with column i at bit i, so any width works. A row's
+reverse lets us line up the columns left of a shift point with their mirror images
+and count differences a word at a time.
 **/
 
-func fmtBinary(b uint32) string {
-	return fmt.Sprintf("%032b", b)
-}
+// Row holds one line of a pattern, rocks are set bits.
+type Row []uint64
 
-func lmask(length, mirrorPoint int) uint32 {
-	if mirrorPoint > length/2 {
-		return uint32(math.Pow(2, float64(length-mirrorPoint)) - 1)
+func parseRow(line string) Row {
+	r := make(Row, (len(line)+63)/64)
+	for i, c := range line {
+		if c == '#' {
+			r[i/64] |= 1 << (i % 64)
+		}
 	}
-	return uint32(math.Pow(2, 32) - 1)
+	return r
 }
 
-func rmask(length, mirrorPoint int) uint32 {
-	if mirrorPoint <= length/2 {
-		return uint32(math.Pow(2, float64(mirrorPoint)) - 1)
+func (r Row) Bit(i int) bool {
+	return r[i/64]&(1<<(i%64)) != 0
+}
+
+// extract returns n <= 64 bits starting at column start.
+func (r Row) extract(start, n int) uint64 {
+	word, offset := start/64, start%64
+	v := r[word] >> offset
+	if offset > 0 && offset+n > 64 {
+		v |= r[word+1] << (64 - offset)
+	}
+	if n < 64 {
+		v &= 1<<n - 1
 	}
-	return uint32(math.Pow(2, float64(length-mirrorPoint)) - 1)
+	return v
 }
 
-func mirror(original uint32, length, mirrorPoint int) uint32 {
-	left := original >> (length - mirrorPoint)
-	left &= lmask(length, mirrorPoint)
-
-	reversed := bits.Reverse32(original)
-	reversed >>= (32 - (length - mirrorPoint))
-	reversed &= rmask(length, mirrorPoint)
-
-	diff := left ^ reversed
-
-	slog.Debug("diff",
-		"original",
-		fmtBinary(original),
-		"left",
-		fmtBinary(left),
-		"reversed",
-		fmtBinary(reversed),
-		"originalL",
-		length,
-		"mirrorPoint",
-		mirrorPoint,
-		"diff",
-		fmtBinary(diff),
-	)
-
-	return diff //|| bits.TrailingZeros(diff) >= originalL-mirrorPoint
+func (r Row) reverse(width int) Row {
+	reversed := make(Row, len(r))
+	for i := 0; i < width; i++ {
+		if r.Bit(i) {
+			j := width - 1 - i
+			reversed[j/64] |= 1 << (j % 64)
+		}
+	}
+	return reversed
 }
 
-type Shift struct {
-	S    int
-	DAcc int
+func (r Row) String(width int) string {
+	s := make([]byte, width)
+	for i := range s {
+		if r.Bit(i) {
+			s[i] = '#'
+		} else {
+			s[i] = '.'
+		}
+	}
+	return string(s)
 }
 
-func (s *Shift) String() string {
-	return fmt.Sprintf("[S: %d, DAcc: %d]", s.S, s.DAcc)
+type Pattern struct {
+	Width    int
+	Rows     []Row
+	reversed []Row
 }
 
-func splitIndex(p []string, allowedDifferences int) int {
-	initialShifts := make([]*Shift, 0)
-	for i := 1; i < len(p[0]); i++ {
-		initialShifts = append(initialShifts, &Shift{i, 0})
+func NewPattern(lines []string) *Pattern {
+	p := &Pattern{Width: len(lines[0])}
+	for _, l := range lines {
+		r := parseRow(l)
+		p.Rows = append(p.Rows, r)
+		p.reversed = append(p.reversed, r.reverse(p.Width))
 	}
-	validShifts := [][]*Shift{initialShifts}
-
-	for _, r := range p {
-		previousShifts := validShifts[len(validShifts)-1]
-		if len(previousShifts) == 0 {
-			continue
-		}
-
-		theseShifts := []*Shift{}
-		tmp, _ := strconv.ParseInt(r, 2, 32)
-		bin := uint32(tmp)
+	return p
+}
 
-		for _, s := range previousShifts {
-			newS := &Shift{
-				S:    s.S,
-				DAcc: s.DAcc + bits.OnesCount32(mirror(bin, len(r), s.S)),
-			}
-			if newS.DAcc <= allowedDifferences {
-				theseShifts = append(theseShifts, newS)
+// Transpose flips the pattern over its diagonal so horizontal lines become vertical ones.
+func (p *Pattern) Transpose() *Pattern {
+	lines := make([]string, p.Width)
+	for col := range lines {
+		line := make([]byte, len(p.Rows))
+		for row, r := range p.Rows {
+			line[row] = '.'
+			if r.Bit(col) {
+				line[row] = '#'
 			}
 		}
-		validShifts = append(validShifts, theseShifts)
+		lines[col] = string(line)
 	}
+	return NewPattern(lines)
+}
+
+type Cell struct {
+	Row int
+	Col int
+}
+
+func (c Cell) String() string {
+	return fmt.Sprintf("(%d,%d)", c.Row, c.Col)
+}
+
+// Reflection is a mirror line along with the cells that had to be smudged to make it
+// fit. Index counts the columns left of a vertical line or the rows above a horizontal one,
+// and smudges are reported on that same side of the line.
+type Reflection struct {
+	Horizontal bool
+	Index      int
+	Smudges    []Cell
+}
 
-	slog.Debug(
-		"finished computing shifts",
-		"validShifts", validShifts,
-	)
+func (r *Reflection) String() string {
+	orientation := "vertical"
+	if r.Horizontal {
+		orientation = "horizontal"
+	}
+	return fmt.Sprintf("%s at %d, smudges %v", orientation, r.Index, r.Smudges)
+}
 
-	if len(validShifts) >= len(p) && len(validShifts[len(validShifts)-1]) > 0 {
-		for _, s := range validShifts[len(validShifts)-1] {
-			if s.DAcc == allowedDifferences {
-				return s.S
+// verticalReflections finds every vertical line where the pattern mirrors itself
+// with exactly allowedDifferences mismatched cells.
+func (p *Pattern) verticalReflections(allowedDifferences int) []*Reflection {
+	found := []*Reflection{}
+	for shift := 1; shift < p.Width; shift++ {
+		span := min(shift, p.Width-shift)
+		differences := 0
+		smudges := []Cell{}
+		for row := 0; row < len(p.Rows) && differences <= allowedDifferences; row++ {
+			// columns shift+k line up with shift-1-k, which sits at width-shift+k once reversed
+			for k := 0; k < span; k += 64 {
+				n := min(64, span-k)
+				diff := p.Rows[row].extract(shift+k, n) ^ p.reversed[row].extract(p.Width-shift+k, n)
+				differences += bits.OnesCount64(diff)
+				for diff != 0 {
+					offset := bits.TrailingZeros64(diff)
+					smudges = append(smudges, Cell{row, shift - 1 - k - offset})
+					diff &= diff - 1
+				}
 			}
 		}
+		slog.Debug("checked shift", "shift", shift, "differences", differences)
+		if differences == allowedDifferences {
+			found = append(found, &Reflection{Index: shift, Smudges: smudges})
+		}
 	}
-	return 0
+	return found
 }
 
-func verticalLeftSplit(pattern []string, allowedDifferences int) int {
-	leftSplitIdx := splitIndex(pattern, allowedDifferences)
-	if leftSplitIdx > 0 {
-		slog.Debug(
-			"finished computing valid vertical split",
-			"left split index", leftSplitIdx)
+// Reflections finds the vertical then horizontal mirror lines of the pattern.
+func (p *Pattern) Reflections(allowedDifferences int) []*Reflection {
+	found := p.verticalReflections(allowedDifferences)
+	for _, r := range p.Transpose().verticalReflections(allowedDifferences) {
+		r.Horizontal = true
+		for i, s := range r.Smudges {
+			r.Smudges[i] = Cell{s.Col, s.Row}
+		}
+		found = append(found, r)
 	}
-	return leftSplitIdx
+	return found
 }
 
-func horizontalAboveSplit(pattern []string, allowedDifferences int) int {
-	rotatedPattern := make([]string, len(pattern[0]))
-	for i := 0; i < len(pattern[0]); i++ {
-		for j := 0; j < len(pattern); j++ {
-			rotatedPattern[i] = rotatedPattern[i] + string(pattern[j][i])
+// firstReflection is the first vertical, or horizontal, reflection found, nil when there
+// is none.
+func firstReflection(reflections []*Reflection, horizontal bool) *Reflection {
+	for _, r := range reflections {
+		if r.Horizontal == horizontal {
+			return r
 		}
 	}
-	slog.Debug("rotated pattern", "p", rotatedPattern)
-	aboveSplitIdx := splitIndex(rotatedPattern, allowedDifferences)
-	if aboveSplitIdx > 0 {
-		slog.Debug(
-			"finished computing valid horizontal split",
-			"above split index", aboveSplitIdx)
-	}
-	return aboveSplitIdx
+	return nil
 }
 
-func partOne(puzzleFile string) {
-	slog.Info("Day Thirteen part one", "puzzle file", puzzleFile)
-	f, err := os.Open(puzzleFile)
-	if err != nil {
-		log.Fatal(err)
+// firstSplit is the index of the first vertical, or horizontal, reflection found, 0 when
+// there is none.
+func firstSplit(reflections []*Reflection, horizontal bool) int {
+	if r := firstReflection(reflections, horizontal); r != nil {
+		return r.Index
 	}
+	return 0
+}
 
-	sc := bufio.NewScanner(f)
-	sc.Scan()
-	ans := sc.Text()
+// renderPatterns draws the patterns one under the other, shading the part of each that
+// mirrors across the reflections that counted and dotting the smudges.
+func renderPatterns(patterns []*Pattern, counted [][]*Reflection, request *render.Request) {
+	width := 0
+	for _, p := range patterns {
+		width = max(width, p.Width)
+	}
 
-	patterns := make([][]string, 1)
-	for sc.Scan() {
-		row := ""
-		t := sc.Text()
-		if t == "" {
-			patterns = append(patterns, []string{})
-			continue
+	rows := []string{}
+	mirrored := render.Visited{}
+	smudges := render.Points{}
+	for i, p := range patterns {
+		top := len(rows)
+		for _, r := range p.Rows {
+			rows = append(rows, r.String(p.Width))
 		}
-		for _, c := range t {
-			if c == '#' {
-				row += "1"
-			} else {
-				row += "0"
+		for _, r := range counted[i] {
+			// along runs across the mirror line, across runs beside it
+			along, across := p.Width, len(p.Rows)
+			if r.Horizontal {
+				along, across = across, along
+			}
+			span := min(r.Index, along-r.Index)
+			for a := r.Index - span; a < r.Index+span; a++ {
+				for b := 0; b < across; b++ {
+					c := coordinates.Coordinate{Row: top + b, Col: a}
+					if r.Horizontal {
+						c = coordinates.Coordinate{Row: top + a, Col: b}
+					}
+					mirrored = append(mirrored, c)
+				}
+			}
+			for _, s := range r.Smudges {
+				smudges = append(smudges, coordinates.Coordinate{Row: top + s.Row, Col: s.Col})
 			}
 		}
-		patterns[len(patterns)-1] = append(patterns[len(patterns)-1], row)
+		// leave a gap between patterns
+		rows = append(rows, strings.Repeat(" ", width))
 	}
-
-	verticalLeftSum := 0
-	horizontalAboveSum := 0
-
-	for i, p := range patterns {
-		// try vertical
-		l := verticalLeftSplit(p, 0)
-		verticalLeftSum += l
-
-		// try horizontal
-		h := horizontalAboveSplit(p, 0)
-		horizontalAboveSum += h
-
-		slog.Debug(
-			"finished computing split",
-			"pattern", i,
-			"vert", l,
-			"horizontal", h,
-		)
+	if err := request.Write(rows, mirrored, smudges); err != nil {
+		log.Fatal(err)
 	}
-
-	slog.Info("Finished day thirteen part one", "expected", ans, "value", verticalLeftSum+horizontalAboveSum*100)
 }
 
-func partTwo(puzzleFile string) {
-	slog.Info("Day Thirteen part two", "puzzle file", puzzleFile)
+// readPatterns returns the expected answer from the first line and the patterns after it.
+func readPatterns(puzzleFile string) (string, []*Pattern) {
 	f, err := os.Open(puzzleFile)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer f.Close()
 
 	sc := bufio.NewScanner(f)
 	sc.Scan()
 	ans := sc.Text()
 
-	patterns := make([][]string, 1)
+	patterns := []*Pattern{}
+	lines := []string{}
 	for sc.Scan() {
-		row := ""
 		t := sc.Text()
 		if t == "" {
-			patterns = append(patterns, []string{})
-			continue
-		}
-		for _, c := range t {
-			if c == '#' {
-				row += "1"
-			} else {
-				row += "0"
+			if len(lines) > 0 {
+				patterns = append(patterns, NewPattern(lines))
 			}
+			lines = []string{}
+			continue
 		}
-		patterns[len(patterns)-1] = append(patterns[len(patterns)-1], row)
+		lines = append(lines, t)
 	}
+	if len(lines) > 0 {
+		patterns = append(patterns, NewPattern(lines))
+	}
+
+	return ans, patterns
+}
 
+// summarize adds up the splits, returning the reflections that counted for each pattern.
+func summarize(patterns []*Pattern, allowedDifferences int, reportAll bool) (int, [][]*Reflection) {
 	verticalLeftSum := 0
 	horizontalAboveSum := 0
+	counted := make([][]*Reflection, len(patterns))
 
 	for i, p := range patterns {
-		// try vertical
-		l := verticalLeftSplit(p, 1)
+		reflections := p.Reflections(allowedDifferences)
+		if reportAll {
+			for _, r := range reflections {
+				fmt.Printf("pattern %d: %s\n", i, r)
+			}
+		}
+
+		l := firstSplit(reflections, false)
 		verticalLeftSum += l
 
-		// try horizontal
-		h := horizontalAboveSplit(p, 1)
+		h := firstSplit(reflections, true)
 		horizontalAboveSum += h
 
+		for _, horizontal := range []bool{false, true} {
+			if r := firstReflection(reflections, horizontal); r != nil {
+				counted[i] = append(counted[i], r)
+			}
+		}
+
 		slog.Debug(
 			"finished computing split",
 			"pattern", i,
@@ -247,21 +308,43 @@ func partTwo(puzzleFile string) {
 		)
 	}
 
-	slog.Info("Finished day thirteen part two", "expected", ans, "value", verticalLeftSum+horizontalAboveSum*100)
+	return verticalLeftSum + horizontalAboveSum*100, counted
+}
+
+// solve summarizes the patterns, part one and two only differ in how many smudges they allow.
+func solve(puzzleFile string, part string, allowedDifferences int, reportAll bool, request *render.Request) {
+	slog.Info("Day Thirteen part "+part, "puzzle file", puzzleFile)
+	ans, patterns := readPatterns(puzzleFile)
+
+	value, counted := summarize(patterns, allowedDifferences, reportAll)
+	renderPatterns(patterns, counted, request)
+
+	slog.Info("Finished day thirteen part "+part, "expected", ans, "value", value)
 }
 
 var Cmd = &cobra.Command{
 	Use: "dayThirteen",
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
+		reportAll, _ := cmd.Flags().GetBool("report-all")
+		allowedDifferences, _ := cmd.Flags().GetInt("allowed-differences")
+		request, err := render.FromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
 		if !cmd.Flag("part-two").Changed {
-			partOne(puzzleInput)
+			solve(puzzleInput, "one", allowedDifferences, reportAll, request)
 		} else {
-			partTwo(puzzleInput)
+			if !cmd.Flag("allowed-differences").Changed {
+				allowedDifferences = 1
+			}
+			solve(puzzleInput, "two", allowedDifferences, reportAll, request)
 		}
 	},
 }
 
 func init() {
 	Cmd.Flags().Bool("part-two", false, "Whether to run part two of the day's challenge")
+	Cmd.Flags().Int("allowed-differences", 0, "Smudged cells a reflection must have, defaults to 1 for part two")
+	Cmd.Flags().Bool("report-all", false, "Print every reflection line and its smudged cells")
 }
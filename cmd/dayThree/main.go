@@ -1,227 +1,250 @@
 package dayThree
 
 import (
+	"adventofcode/cmd/coordinates"
+	"adventofcode/cmd/render"
 	"bufio"
 	"encoding/json"
 	"fmt"
 	"log"
 	"log/slog"
 	"os"
+	"strings"
 	"unicode"
 
 	"github.com/spf13/cobra"
 )
 
-type SchematicEntry struct {
-	PartNumber  int
-	Symbol      string
-	Xs          []int
-	Y           int
-	isValidPart bool
-	GearParts   map[string]int
+// Number is a run of digits on a single row of the schematic.
+type Number struct {
+	Value int
+	Row   int
+	Start int
+	End   int
 }
 
-func (e *SchematicEntry) IsSymbol() bool {
-	return e.Symbol != "" && e.Symbol != "."
+func (n *Number) String() string {
+	return fmt.Sprintf("%d@(%d,%d-%d)", n.Value, n.Row, n.Start, n.End)
 }
 
-func (e *SchematicEntry) AddGearPart(p *SchematicEntry) {
-	if _, ok := e.GearParts[e.Id()]; !ok {
-		e.GearParts[p.Id()] = p.PartNumber
-	}
+type Symbol struct {
+	Glyph rune
+	Row   int
+	Col   int
 }
 
-func (s *SchematicEntry) IsValidPart(schematic map[string]*SchematicEntry) bool {
-	if s.isValidPart {
-		return true
-	}
-	if s.IsSymbol() {
-		return false
-	}
-	xs := append([]int{s.Xs[0] - 1}, s.Xs...)
-	xs = append(xs, s.Xs[len(s.Xs)-1]+1)
-	for _, x := range xs {
-		for _, row := range []int{s.Y - 1, s.Y, s.Y + 1} {
-			if e, ok := schematic[id(x, row)]; ok && e.IsSymbol() {
-				s.isValidPart = true
-				return true
-			}
-		}
-	}
-
-	return false
+func (s *Symbol) String() string {
+	return fmt.Sprintf("%c@(%d,%d)", s.Glyph, s.Row, s.Col)
 }
 
-func (s *SchematicEntry) IsGear(schematic map[string]*SchematicEntry) bool {
-	if s.Symbol != "*" {
-		return false
-	}
-
-	xs := append([]int{s.Xs[0] - 1}, s.Xs...)
-	xs = append(xs, s.Xs[len(s.Xs)-1]+1)
-	for _, x := range xs {
-		for _, row := range []int{s.Y - 1, s.Y, s.Y + 1} {
-			if e, ok := schematic[id(x, row)]; ok && e.IsValidPart(schematic) {
-				slog.Debug("adding a new gear part", "s.Id", s.Id(), "gear part", e)
-				s.AddGearPart(e)
-			}
-		}
-	}
+func isSpace(c rune) bool {
+	return c == '.'
+}
 
-	slog.Debug(
-		"calculated gear parts",
-		"s.Id", s.Id(),
-		"gear parts", s.GearParts,
-		"length of gears", len(s.GearParts),
-	)
-	return len(s.GearParts) == 2
+func isSymbol(c rune) bool {
+	return !isSpace(c) && !unicode.IsDigit(c)
 }
 
-// NAUGHTY: this relies on IsGear being called first, should prepopulate gearParts at construction
-// meh
-func (e *SchematicEntry) GearRatio() int {
-	ratio := 1
-	for _, partNumber := range e.GearParts {
-		ratio *= partNumber
-	}
-	return ratio
+// Schematic indexes every cell by the number or symbol sitting in it, so neighbours
+// are plain grid lookups.
+type Schematic struct {
+	Rows          []string
+	Height, Width int
+	Numbers       []*Number
+	Symbols       []*Symbol
+	numberAt      [][]*Number
+	symbolAt      [][]*Symbol
 }
 
-func (e *SchematicEntry) String() string {
-	s, err := json.Marshal(e)
+func (s *Schematic) String() string {
+	j, err := json.Marshal(struct {
+		Numbers []*Number
+		Symbols []*Symbol
+	}{s.Numbers, s.Symbols})
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	return string(s)
+	return string(j)
 }
 
-func (e *SchematicEntry) Id() string {
-	return id(e.Xs[0], e.Y)
+func (s *Schematic) inBounds(row, col int) bool {
+	return row >= 0 && row < s.Height && col >= 0 && col < s.Width
 }
 
-func id(x, y int) string {
-	return fmt.Sprintf("%d, %d", x, y)
-}
-
-func isSpace(c rune) bool {
-	return c == '.'
-}
-
-func BuildSchematic(path string) map[string]*SchematicEntry {
+func BuildSchematic(path string) *Schematic {
 	f, err := os.Open(path)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer f.Close()
 
 	scanner := bufio.NewScanner(f)
 	rows := []string{}
-
 	for scanner.Scan() {
-		// Not my favorite, but I'm getting lazy today
 		rows = append(rows, scanner.Text())
 	}
 
-	schematicEntries := []*SchematicEntry{}
-	xs := []int{}
+	s := &Schematic{Rows: rows, Height: len(rows)}
+	for _, row := range rows {
+		s.Width = max(s.Width, len(row))
+	}
+	s.numberAt = make([][]*Number, s.Height)
+	s.symbolAt = make([][]*Symbol, s.Height)
 
 	for i, row := range rows {
-		candidatePart := 0
+		s.numberAt[i] = make([]*Number, s.Width)
+		s.symbolAt[i] = make([]*Symbol, s.Width)
 
+		var current *Number
 		for j, c := range row {
-			atEnd := j == len(row)-1
-
 			if unicode.IsDigit(c) {
-				candidatePart = candidatePart*10 + int(c-'0')
-				xs = append(xs, j)
-
-				if !atEnd {
-					continue
+				if current == nil {
+					current = &Number{Row: i, Start: j}
+					s.Numbers = append(s.Numbers, current)
 				}
+				current.Value = current.Value*10 + int(c-'0')
+				current.End = j
+				s.numberAt[i][j] = current
+				continue
+			}
+
+			current = nil
+			if isSymbol(c) {
+				sym := &Symbol{Glyph: c, Row: i, Col: j}
+				s.Symbols = append(s.Symbols, sym)
+				s.symbolAt[i][j] = sym
 			}
+		}
+	}
+
+	return s
+}
 
-			e := &SchematicEntry{
-				PartNumber: 0,
-				Symbol:     string(c),
-				Xs:         []int{j},
-				Y:          i,
-				GearParts:  map[string]int{},
+// NumbersAdjacentTo lists each distinct number touching the symbol, diagonals included.
+func (s *Schematic) NumbersAdjacentTo(sym *Symbol) []*Number {
+	numbers := []*Number{}
+	for row := sym.Row - 1; row <= sym.Row+1; row++ {
+		var last *Number
+		for col := sym.Col - 1; col <= sym.Col+1; col++ {
+			if !s.inBounds(row, col) {
+				continue
 			}
-			if e.IsSymbol() {
-				schematicEntries = append(schematicEntries, e)
+			// a number spans neighbouring cells, only count it once per row
+			if n := s.numberAt[row][col]; n != nil && n != last {
+				numbers = append(numbers, n)
+				last = n
 			}
+		}
+	}
+	return numbers
+}
 
-			if candidatePart != 0 || atEnd {
-				schematicEntries = append(
-					schematicEntries,
-					&SchematicEntry{
-						PartNumber: candidatePart,
-						Symbol:     "",
-						Xs:         xs,
-						Y:          i,
-						GearParts:  map[string]int{},
-					})
-
-				candidatePart = 0
-				xs = []int{}
+// SymbolsAdjacentTo lists the symbols touching any digit of the number.
+func (s *Schematic) SymbolsAdjacentTo(n *Number) []*Symbol {
+	symbols := []*Symbol{}
+	for row := n.Row - 1; row <= n.Row+1; row++ {
+		for col := n.Start - 1; col <= n.End+1; col++ {
+			if s.inBounds(row, col) && s.symbolAt[row][col] != nil {
+				symbols = append(symbols, s.symbolAt[row][col])
 			}
 		}
 	}
+	return symbols
+}
 
-	schematic := map[string]*SchematicEntry{}
-	for _, e := range schematicEntries {
-		for _, x := range e.Xs {
-			schematic[id(x, e.Y)] = e
+// inSet matches any symbol when glyphs is empty.
+func inSet(glyph rune, glyphs string) bool {
+	return glyphs == "" || strings.ContainsRune(glyphs, glyph)
+}
+
+// SymbolsAdjacentToExactly finds symbols from the glyph set touching exactly count numbers.
+func (s *Schematic) SymbolsAdjacentToExactly(count int, glyphs string) []*Symbol {
+	symbols := []*Symbol{}
+	for _, sym := range s.Symbols {
+		if inSet(sym.Glyph, glyphs) && len(s.NumbersAdjacentTo(sym)) == count {
+			symbols = append(symbols, sym)
 		}
 	}
+	return symbols
+}
 
-	return schematic
+// NumbersAdjacentToAny finds numbers touching at least one symbol from the glyph set.
+func (s *Schematic) NumbersAdjacentToAny(glyphs string) []*Number {
+	numbers := []*Number{}
+	for _, n := range s.Numbers {
+		for _, sym := range s.SymbolsAdjacentTo(n) {
+			if inSet(sym.Glyph, glyphs) {
+				numbers = append(numbers, n)
+				break
+			}
+		}
+	}
+	return numbers
+}
+
+// Gears are stars touching exactly two part numbers.
+func (s *Schematic) Gears() []*Symbol {
+	return s.SymbolsAdjacentToExactly(2, "*")
+}
+
+func (s *Schematic) Ratio(sym *Symbol) int {
+	ratio := 1
+	for _, n := range s.NumbersAdjacentTo(sym) {
+		ratio *= n.Value
+	}
+	return ratio
 }
 
-func SumGearRatios(path string) []int {
-	return nil
+// renderSchematic shades the digits of the numbers that counted and dots the symbols.
+func renderSchematic(s *Schematic, numbers []*Number, symbols []*Symbol, request *render.Request) {
+	digits := render.Visited{}
+	for _, n := range numbers {
+		for col := n.Start; col <= n.End; col++ {
+			digits = append(digits, coordinates.Coordinate{Row: n.Row, Col: col})
+		}
+	}
+	marked := render.Points{}
+	for _, sym := range symbols {
+		marked = append(marked, coordinates.Coordinate{Row: sym.Row, Col: sym.Col})
+	}
+	if err := request.Write(s.Rows, digits, marked); err != nil {
+		log.Fatal(err)
+	}
 }
 
-func partOne(puzzleFile string) {
+func partOne(puzzleFile string, glyphs string, request *render.Request) {
 	schematic := BuildSchematic(puzzleFile)
-	slog.Debug("built schematic", "entries", schematic)
+	slog.Debug("built schematic", "schematic", schematic)
 
-	parts := map[string]*SchematicEntry{}
+	parts := schematic.NumbersAdjacentToAny(glyphs)
 	partsSum := 0
-	for _, e := range schematic {
-		if e.IsSymbol() {
-			continue
-		}
-		if _, ok := parts[e.Id()]; !ok {
-			if e.IsValidPart(schematic) {
-				parts[e.Id()] = e
-				partsSum += e.PartNumber
-			}
-		}
+	for _, p := range parts {
+		partsSum += p.Value
 	}
 
+	renderSchematic(schematic, parts, nil, request)
 	slog.Debug("final sum", "parts", parts, "sum", partsSum)
 	slog.Info("final sum", "sum", partsSum)
 }
 
-func partTwo(puzzleFile string) {
+func partTwo(puzzleFile string, glyphs string, adjacent int, request *render.Request) {
 	schematic := BuildSchematic(puzzleFile)
-	slog.Debug("built schematic", "entries", schematic)
+	slog.Debug("built schematic", "schematic", schematic)
 
-	gears := map[string]*SchematicEntry{}
+	// the puzzle's gears unless the flags asked about other symbols
+	gears := schematic.Gears()
+	if glyphs != "*" || adjacent != 2 {
+		gears = schematic.SymbolsAdjacentToExactly(adjacent, glyphs)
+	}
 	gearRatiosSum := 0
-	for _, e := range schematic {
-		if !e.IsGear(schematic) {
-			continue
-		}
-
-		if _, ok := gears[e.Id()]; !ok {
-			gears[e.Id()] = e
-			gearRatiosSum += e.GearRatio()
-		}
+	geared := []*Number{}
+	for _, g := range gears {
+		gearRatiosSum += schematic.Ratio(g)
+		geared = append(geared, schematic.NumbersAdjacentTo(g)...)
 	}
 
-	slog.Debug("final sum", "parts", gears, "sum", gearRatiosSum)
+	renderSchematic(schematic, geared, gears, request)
+	slog.Debug("final sum", "gears", gears, "sum", gearRatiosSum)
 	slog.Info("final sum", "sum", gearRatiosSum)
 }
 
@@ -229,10 +252,24 @@ var Cmd = &cobra.Command{
 	Use: "dayThree",
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
+		glyphs, _ := cmd.Flags().GetString("glyphs")
+		request, err := render.FromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
 		if !cmd.Flag("part-two").Changed {
-			partOne(puzzleInput)
+			partOne(puzzleInput, glyphs, request)
 		} else {
-			partTwo(puzzleInput)
+			if !cmd.Flag("glyphs").Changed {
+				glyphs = "*"
+			}
+			adjacent, _ := cmd.Flags().GetInt("adjacent")
+			partTwo(puzzleInput, glyphs, adjacent, request)
 		}
 	},
 }
+
+func init() {
+	Cmd.Flags().String("glyphs", "", "Symbols to query, empty for any symbol, defaults to * for part two")
+	Cmd.Flags().Int("adjacent", 2, "Numbers a symbol must touch, only applicable for part two")
+}
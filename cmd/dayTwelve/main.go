@@ -2,13 +2,12 @@ package dayTwelve
 
 import (
 	"adventofcode/cmd/scanner"
-	"bufio"
 	"fmt"
 	"log"
 	"log/slog"
-	"os"
-	"strconv"
-	"strings"
+	"runtime"
+	"sync"
+	"sync/atomic"
 
 	"github.com/alecthomas/participle/v2"
 	"github.com/spf13/cobra"
@@ -53,14 +52,6 @@ type ConditionRecord struct {
 	GroupSizes []int    `(@Int ","?)+`
 }
 
-type TrueCondition struct {
-	TrueConditions []Condition
-	conditionsI    int
-	sizesI         int
-	truesI         int
-	damagedCount   int
-}
-
 func (c ConditionRecord) String() string {
 	return fmt.Sprintf(
 		"Conditions: %v, GroupSizes: %v",
@@ -69,17 +60,6 @@ func (c ConditionRecord) String() string {
 	)
 }
 
-func (t TrueCondition) String() string {
-	return fmt.Sprintf(
-		"trues: %v, conditionsI: %d, sizesI: %d, truesI: %d, damagedCount: %d",
-		t.TrueConditions,
-		t.conditionsI,
-		t.sizesI,
-		t.truesI,
-		t.damagedCount,
-	)
-}
-
 func (c *ConditionRecord) UnknownCount() int {
 	count := 0
 	for _, cond := range c.Conditions {
@@ -91,295 +71,175 @@ func (c *ConditionRecord) UnknownCount() int {
 
 }
 
-var VALID_CACHE = make(map[string]bool)
-
-func (c *ConditionRecord) GenerateReplacements() []*TrueCondition {
-	ret := []*TrueCondition{{TrueConditions: make([]Condition, 0)}}
-	for i := 0; i < c.UnknownCount(); i++ {
-		newTCs := make([]*TrueCondition, 0)
-		for _, tc := range ret {
-			for _, cond := range []Condition{Operational, Damaged} {
-				newTC := &TrueCondition{
-					TrueConditions: tc.TrueConditions,
-					conditionsI:    tc.conditionsI,
-					sizesI:         tc.sizesI,
-					truesI:         tc.truesI,
-					damagedCount:   tc.damagedCount,
-				}
-				newTC.TrueConditions = append(newTC.TrueConditions, cond)
-
-				var isValid bool
-				if cacheVal, ok := VALID_CACHE[newTC.String()]; ok {
-					isValid = cacheVal
-				} else {
-					isValid = c.IsValid(newTC)
-					VALID_CACHE[newTC.String()] = isValid
-				}
-
-				if isValid {
-					slog.Debug("found replacement", "cond", c, "tc", newTC)
-					newTCs = append(newTCs, newTC)
-				}
-			}
+// Unfold repeats the record factor times, joining the condition copies with an
+// unknown spring. A factor of one leaves the record as is.
+func (c *ConditionRecord) Unfold(factor int) *ConditionRecord {
+	unfolded := &ConditionRecord{
+		Conditions: make([]string, 0, factor*(len(c.Conditions)+1)),
+		GroupSizes: make([]int, 0, factor*len(c.GroupSizes)),
+	}
+	for i := 0; i < factor; i++ {
+		if i > 0 {
+			unfolded.Conditions = append(unfolded.Conditions, Unknown.String())
 		}
-		ret = newTCs
+		unfolded.Conditions = append(unfolded.Conditions, c.Conditions...)
+		unfolded.GroupSizes = append(unfolded.GroupSizes, c.GroupSizes...)
 	}
-	return ret
+	return unfolded
 }
 
-func (c *ConditionRecord) IsValid(tc *TrueCondition) bool {
-	for tc.conditionsI < len(c.Conditions) {
-		cond := NewCondition(rune(c.Conditions[tc.conditionsI][0]))
-		if cond == Unknown {
-			if tc.truesI >= len(tc.TrueConditions) {
-				// we need more replacement options
-				return c.UnknownCount() != len(tc.TrueConditions)
-			}
-
-			cond = tc.TrueConditions[tc.truesI]
-			tc.truesI++
-		}
+// arrangementSolver counts the ways a record's unknowns can be filled in. The cache
+// belongs to the solver, so each record gets a fresh one keyed on the condition and
+// group size indices.
+type arrangementSolver struct {
+	conditions []Condition
+	groupSizes []int
+	// operational[i] is the number of operational springs in conditions[:i]
+	operational []int
+	cache       [][]int
+}
 
-		switch cond {
-		case Operational:
-			if tc.damagedCount > 0 {
-				if tc.sizesI >= len(c.GroupSizes) {
-					return false
-				}
-				if tc.damagedCount != c.GroupSizes[tc.sizesI] {
-					return false
-				}
-				tc.damagedCount = 0
-				tc.sizesI++
-			}
-		case Damaged:
-			tc.damagedCount++
-		default:
-			panic("Invalid condition")
-		}
-		tc.conditionsI++
+func newArrangementSolver(c *ConditionRecord) *arrangementSolver {
+	s := &arrangementSolver{
+		conditions:  make([]Condition, len(c.Conditions)),
+		groupSizes:  c.GroupSizes,
+		operational: make([]int, len(c.Conditions)+1),
+		cache:       make([][]int, len(c.Conditions)+1),
 	}
-
-	if tc.damagedCount > 0 {
-		if tc.sizesI >= len(c.GroupSizes) {
-			return false
-		}
-		if tc.damagedCount != c.GroupSizes[tc.sizesI] {
-			return false
+	for i, cond := range c.Conditions {
+		s.conditions[i] = NewCondition(rune(cond[0]))
+		s.operational[i+1] = s.operational[i]
+		if s.conditions[i] == Operational {
+			s.operational[i+1]++
 		}
-		if tc.conditionsI == len(c.Conditions) {
-			tc.sizesI++
+	}
+	for i := range s.cache {
+		s.cache[i] = make([]int, len(c.GroupSizes)+1)
+		for j := range s.cache[i] {
+			s.cache[i][j] = -1
 		}
 	}
+	return s
+}
 
-	if tc.conditionsI == len(c.Conditions) && tc.sizesI < len(c.GroupSizes) {
+// fits reports whether a damaged group of the given size can start at condI.
+func (s *arrangementSolver) fits(condI, size int) bool {
+	end := condI + size
+	if end > len(s.conditions) {
 		return false
 	}
-
-	return true
-}
-
-func newScanner(puzzleFile string) *scanner.PuzzleScanner[ConditionRecord] {
-	parser, err := participle.Build[ConditionRecord]()
-	if err != nil {
-		log.Fatal(err)
+	if s.operational[end] != s.operational[condI] {
+		return false
 	}
-
-	return scanner.NewScanner[ConditionRecord](parser, puzzleFile)
+	return end == len(s.conditions) || s.conditions[end] != Damaged
 }
-func partOne(puzzleFile string) {
-	slog.Info("Day Twelve part one", "puzzle file", puzzleFile)
-	sc := newScanner(puzzleFile)
 
-	sumOptions := 0
-	for sc.Scan() {
-		r := sc.Struct()
-		slog.Debug("Checking Record", "record", r, "unknown count", r.UnknownCount())
-		replacementOptions := r.GenerateReplacements()
-		sumOptions += len(replacementOptions)
-
-		slog.Debug("parsed record", "record", r, "total valid", len(replacementOptions), "valid options", replacementOptions)
-		slog.Info("parsed record")
+func (s *arrangementSolver) count(condI, sizeI int) int {
+	if condI >= len(s.conditions) {
+		if sizeI == len(s.groupSizes) {
+			return 1
+		}
+		return 0
+	}
+	if cached := s.cache[condI][sizeI]; cached >= 0 {
+		return cached
 	}
 
-	slog.Info("finished day twelve part one", "sum options", sumOptions)
-}
-
-func partTwo(puzzleFile string) {
-	slog.Info("Day Twelve part two", "puzzle file", puzzleFile)
-	sc := newScanner(puzzleFile)
-
-	sumOptions := 0
-	for sc.Scan() {
-		r := sc.Struct()
-		newCons := r.Conditions
-		newGs := r.GroupSizes
-		for i := 0; i < 4; i++ {
-			newCons = append(newCons, "?")
-			newCons = append(newCons, r.Conditions...)
-			newGs = append(newGs, r.GroupSizes...)
-		}
-		r.Conditions = newCons
-		r.GroupSizes = newGs
-		slog.Debug("Checking Record", "record", r, "unknown count", r.UnknownCount())
-		replacementOptions := r.GenerateReplacements()
-		sumOptions += len(replacementOptions)
+	total := 0
+	cond := s.conditions[condI]
+	if cond != Damaged {
+		// leave this spring operational
+		total += s.count(condI+1, sizeI)
+	}
+	if cond != Operational && sizeI < len(s.groupSizes) && s.fits(condI, s.groupSizes[sizeI]) {
+		// start the next damaged group here, hopping over the spring that closes it
+		total += s.count(condI+s.groupSizes[sizeI]+1, sizeI+1)
 	}
 
-	slog.Info("finished day twelve part two", "sum options", sumOptions)
+	s.cache[condI][sizeI] = total
+	return total
 }
 
-// Borrowed! https://github.com/Rtchaik/AoC-2023/blob/main/Day12/solution.py
-type Data struct {
-	Row        string
-	GroupSizes []int
+// Arrangements counts the assignments of unknown springs that match the group sizes.
+func (c *ConditionRecord) Arrangements() int {
+	return newArrangementSolver(c).count(0, 0)
 }
 
-func withReddit(puzzleFile string) {
-	slog.Info("Day twelve part one with Reddit", "f", puzzleFile)
-	f, err := os.Open(puzzleFile)
+func newScanner(puzzleFile string) *scanner.PuzzleScanner[ConditionRecord] {
+	parser, err := participle.Build[ConditionRecord]()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	sc := bufio.NewScanner(f)
+	return scanner.NewScanner[ConditionRecord](parser, puzzleFile)
+}
 
-	data := []*Data{}
+func readRecords(puzzleFile string) []*ConditionRecord {
+	sc := newScanner(puzzleFile)
+	records := []*ConditionRecord{}
 	for sc.Scan() {
-		d := &Data{
-			Row:        "",
-			GroupSizes: []int{},
-		}
-
-		t := sc.Text()
-		d.Row = strings.Split(t, " ")[0]
-		for _, c := range strings.Split(strings.Split(t, " ")[1], ",") {
-			i, _ := strconv.Atoi(c)
-			d.GroupSizes = append(d.GroupSizes, i)
-		}
-
-		data = append(data, d)
+		records = append(records, sc.Struct())
+	}
+	return records
+}
+
+// sumArrangements unfolds each record and counts its arrangements on a pool of
+// workers, one per CPU.
+func sumArrangements(records []*ConditionRecord, unfoldFactor int) int {
+	jobs := make(chan *ConditionRecord)
+	var total atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range jobs {
+				arrangements := r.Unfold(unfoldFactor).Arrangements()
+				slog.Debug("counted arrangements", "record", r, "unknown count", r.UnknownCount(), "arrangements", arrangements)
+				total.Add(int64(arrangements))
+			}
+		}()
 	}
 
-	sum := 0
-	for _, d := range data {
-		sum += springsFinder(d.Row+".", d.GroupSizes)
-		slog.Debug("processed row", "data", d, "sum", sum)
+	for _, r := range records {
+		jobs <- r
 	}
-	slog.Info("finished day twelve part one with Reddit", "sum", sum)
+	close(jobs)
+	wg.Wait()
 
-	sum = 0
-	for _, d := range data {
-		expandedD := &Data{
-			Row:        d.Row,
-			GroupSizes: d.GroupSizes,
-		}
-		for i := 0; i < 4; i++ {
-			expandedD.Row += "?" + d.Row
-			expandedD.GroupSizes = append(expandedD.GroupSizes, d.GroupSizes...)
-		}
-		sum += springsFinder(expandedD.Row+".", expandedD.GroupSizes)
-	}
-	slog.Info("finished day twelve part two with Reddit", "sum", sum)
+	return int(total.Load())
 }
 
-var SPRINGS_CACHE = make(map[string]int)
+func partOne(puzzleFile string) {
+	slog.Info("Day Twelve part one", "puzzle file", puzzleFile)
 
-func springsCacheKey(row string, nums []int) string {
-	return fmt.Sprintf("%s-%v", row, nums)
-}
+	sumOptions := sumArrangements(readRecords(puzzleFile), 1)
 
-func sum(nums []int) int {
-	sum := 0
-	for _, n := range nums {
-		sum += n
-	}
-	return sum
+	slog.Info("finished day twelve part one", "sum options", sumOptions)
 }
 
-func springsFinder(row string, nums []int) int {
-	slog.Debug("finding springs", "row", row, "nums", nums)
-	if v, ok := SPRINGS_CACHE[springsCacheKey(row, nums)]; ok {
-		return v
-	}
+func partTwo(puzzleFile string, unfoldFactor int) {
+	slog.Info("Day Twelve part two", "puzzle file", puzzleFile, "unfold factor", unfoldFactor)
 
-	next := nums[1:]
-	springs := []string{}
-	for spr := 0; spr < len(row)-sum(nums)-len(next); spr++ {
-		expandedSprings := ""
-		for i := 0; i < spr; i++ {
-			expandedSprings += "."
-		}
-		expandedBreaks := ""
-		for i := 0; i < nums[0]; i++ {
-			expandedBreaks += "#"
-		}
-		springs = append(springs, fmt.Sprintf("%s%s.", expandedSprings, expandedBreaks))
-	}
-
-	valid := []int{}
-	for _, s := range springs {
-		if !isValid(row, s) {
-			continue
-		}
-
-		valid = append(valid, len(s))
-	}
-
-	slog.Debug("found valid springs", "springs", springs, "valid", valid)
-	sum := 0
-	if len(next) == 0 {
-		for _, v := range valid {
-			foundPound := false
-			for i := v; i < len(row); i++ {
-				if row[i] == '#' {
-					foundPound = true
-					break
-				}
-			}
-			if !foundPound {
-				sum++
-			}
-		}
-	} else {
-		for _, v := range valid {
-			sum += springsFinder(row[v:], next)
-		}
-	}
-
-	SPRINGS_CACHE[springsCacheKey(row, nums)] = sum
-	return sum
-}
+	sumOptions := sumArrangements(readRecords(puzzleFile), unfoldFactor)
 
-func isValid(row string, spr string) bool {
-	for i := 0; i < len(row) && i < len(spr); i++ {
-		if row[i] == '?' {
-			continue
-		}
-		if row[i] != spr[i] {
-			return false
-		}
-	}
-	return true
+	slog.Info("finished day twelve part two", "sum options", sumOptions)
 }
 
 var Cmd = &cobra.Command{
 	Use: "dayTwelve",
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
-		if cmd.Flag("with-reddit").Changed {
-			withReddit(puzzleInput)
-			return
-		}
 		if !cmd.Flag("part-two").Changed {
 			partOne(puzzleInput)
 		} else {
-			partTwo(puzzleInput)
+			unfoldFactor, _ := cmd.Flags().GetInt("unfold-factor")
+			partTwo(puzzleInput, unfoldFactor)
 		}
 	},
 }
 
 func init() {
 	Cmd.Flags().Bool("part-two", false, "Whether to run part two of the day's challenge")
-	Cmd.Flags().Bool("with-reddit", false, "Use the suggested solution from Reddit")
+	Cmd.Flags().Int("unfold-factor", 5, "Copies of each record to unfold, only applicable for part two")
 }
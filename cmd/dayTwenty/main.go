@@ -1,10 +1,12 @@
 package dayTwenty
 
 import (
+	"adventofcode/cmd/graph"
 	"fmt"
+	"log"
 	"log/slog"
 	"math"
-	"strings"
+	"slices"
 
 	"github.com/spf13/cobra"
 )
@@ -223,23 +225,48 @@ func partOne(puzzleFile string, pushCount int) {
 	slog.Info("Parsing modules", "low pulses", lowPulses, "high pulses", highPulses, "product", lowPulses*highPulses)
 }
 
-func PrintGraph(f string) {
-	ms := ParseModules(f)
-	fmt.Println("flowchart TD")
-	for _, m := range ms {
-		name := strings.ToUpper(m.Name)
-		switch m.ModuleKind {
-		case "":
-			fmt.Println(fmt.Sprintf("    %s(%s)", name, m.Name))
-		case "%":
-			fmt.Println(fmt.Sprintf("    %s{%s%s}", name, m.ModuleKind, m.Name))
-		case "&":
-			fmt.Println(fmt.Sprintf("    %s[%s%s]", name, m.ModuleKind, m.Name))
+var moduleShapes = map[string]graph.Shape{
+	"":  graph.Rounded,
+	"%": graph.Diamond,
+	"&": graph.Box,
+}
+
+// Graph wires up the modules, shaped by kind. Destinations that aren't modules, like rx, are
+// circles and the conjunction feeding rx is highlighted along with everything sending to it,
+// those are the inputs MinimumForRx watches.
+func Graph(ms map[string]*Module) *graph.Graph {
+	names := []string{}
+	for name := range ms {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	g := graph.New("modules", true)
+	for _, name := range names {
+		m := ms[name]
+		g.AddNode(name, m.ModuleKind+m.Name, moduleShapes[m.ModuleKind])
+	}
+	for _, name := range names {
+		for _, r := range ms[name].Receivers {
+			if _, ok := ms[r]; !ok && g.Node(r) == nil {
+				g.AddNode(r, r, graph.Circle)
+			}
+			g.AddEdge(name, r, "")
 		}
-		for _, r := range m.Receivers {
-			fmt.Println(fmt.Sprintf("    %s --> %s", name, strings.ToUpper(r)))
+	}
+
+	for _, name := range names {
+		if !slices.Contains(ms[name].Receivers, "rx") {
+			continue
+		}
+		g.HighlightPath(name, "rx")
+		for _, src := range names {
+			if slices.Contains(ms[src].Receivers, name) {
+				g.HighlightPath(src, name)
+			}
 		}
 	}
+	return g
 }
 
 func partTwo(puzzleFile string) {
@@ -256,8 +283,14 @@ var Cmd = &cobra.Command{
 	Use: "dayTwenty",
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
-		if cmd.Flag("print-graph").Changed {
-			PrintGraph(puzzleInput)
+		export, err := graph.FromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if export != nil {
+			if err := export.Write(Graph(ParseModules(puzzleInput))); err != nil {
+				log.Fatal(err)
+			}
 		}
 		pushCount, _ := cmd.Flags().GetInt("push-count")
 		if !cmd.Flag("part-two").Changed {
@@ -270,6 +303,5 @@ var Cmd = &cobra.Command{
 
 func init() {
 	Cmd.Flags().Bool("part-two", false, "Whether to run part two of the day's challenge")
-	Cmd.Flags().Bool("print-graph", false, "print our graph")
 	Cmd.Flags().Int("push-count", 1000, "Push count")
 }
@@ -1,12 +1,12 @@
 package dayTwentyFive
 
 import (
-	"adventofcode/cmd/util"
+	"adventofcode/cmd/graph"
 	"bufio"
-	"fmt"
+	"log"
 	"log/slog"
 	"os"
-	"os/exec"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -52,80 +52,29 @@ func productOfTwoComponents(cs map[string][]string) int {
 	return val
 }
 
-func generateMermaidDiagram(cs map[string][]string) {
-	if !util.InDebugMode() {
-		return
-	}
-
-	edges := [][]string{}
-	for u, cons := range cs {
-		for _, v := range cons {
-			edges = append(edges, []string{u, v})
-		}
-	}
-
-	mermaidFile, err := os.Create("/tmp/mermaid_diagram.mmd")
-	if err != nil {
-		slog.Error("Error creating mermaid file", "error", err)
-		return
-	}
-	defer mermaidFile.Close()
-
-	_, _ = mermaidFile.WriteString("%%{init: {\"flowchart\": {\"defaultRenderer\": \"elk\"}} }%%\n")
-	_, err = mermaidFile.WriteString("flowchart LR;\n")
-	if err != nil {
-		slog.Error("Error writing to mermaid file", "error", err)
-		return
-	}
-
-	for _, e := range edges {
-		_, err = mermaidFile.WriteString(
-			fmt.Sprintf(
-				"%s <--> %s\n",
-				e[0],
-				e[1],
-			))
-		if err != nil {
-			slog.Error("Error writing to mermaid file", "error", err)
-			return
-		}
-	}
-	cmd := exec.Command("mmdc", "-i", "/tmp/mermaid_diagram.mmd", "-o", "/tmp/mermaid_diagram.png")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		slog.Error("Error running mmdc command", "error", err, "output", string(output))
-		return
+// Graph draws every wire once, highlighting the cut that splits the machine in two.
+func Graph(cs map[string][]string, cut [][]string) *graph.Graph {
+	names := []string{}
+	for u := range cs {
+		names = append(names, u)
 	}
-	slog.Info("Mermaid diagram generated", "output", string(output), "location", "/tmp/mermaid_diagram.png")
-}
+	slices.Sort(names)
 
-func generateGraphviz(cs map[string][]string) {
-	if !util.InDebugMode() {
-		return
+	g := graph.New("components", false)
+	for _, u := range names {
+		g.AddNode(u, u, "")
 	}
-
-	edges := map[string]bool{}
-	for u, cons := range cs {
-		for _, v := range cons {
-			if _, ok := edges[v+" -- "+u]; !ok {
-				edges[u+"--"+v] = true
+	for _, u := range names {
+		for _, v := range cs[u] {
+			if u < v {
+				g.AddEdge(u, v, "")
 			}
 		}
 	}
-
-	graphvizFile, err := os.Create("/tmp/graphviz_diagram.dot")
-	if err != nil {
-		slog.Error("Error creating graphviz file", "error", err)
-		return
-	}
-	defer graphvizFile.Close()
-
-	_, _ = graphvizFile.WriteString("graph {\n")
-	for e := range edges {
-		_, err = graphvizFile.WriteString(
-			fmt.Sprintf("%s\n", e))
+	for _, e := range cut {
+		g.HighlightEdge(e[0], e[1])
 	}
-	_, _ = graphvizFile.WriteString("}\n")
+	return g
 }
 
 func removeEdge(u0, u1 string, cs map[string][]string) {
@@ -151,7 +100,7 @@ func removeEdge(u0, u1 string, cs map[string][]string) {
 // https://www.sciencedirect.com/science/article/pii/S1570866708000415#sec005
 // ugh, god nevermind
 
-func partOne(puzzleFile string) {
+func partOne(puzzleFile string, export *graph.Request) {
 	slog.Info("Day TwentyFive part one", "puzzle file", puzzleFile)
 	file, err := os.Open(puzzleFile)
 	if err != nil {
@@ -197,28 +146,31 @@ func partOne(puzzleFile string) {
 		slog.Error("Error reading file", "error", err)
 	}
 
-	generateGraphviz(cs)
-
 	// I looked at the graphviz and found these
 	// Helpful tip on the settings to cluster: https://www.reddit.com/r/adventofcode/comments/18qcsux/2023_day_25_part_1_solve_by_visualization/
+	cut := [][]string{
+		{"mnf", "hrs"},
+		{"kpc", "nnl"},
+		{"rkh", "sph"},
+	}
 	if strings.Contains(puzzleFile, "sample") {
-		for _, e := range [][]string{
+		cut = [][]string{
 			{"pzl", "hfx"},
 			{"nvd", "jqt"},
 			{"cmg", "bvb"},
-		} {
-			removeEdge(e[0], e[1], cs)
 		}
-	} else {
-		for _, e := range [][]string{
-			{"mnf", "hrs"},
-			{"kpc", "nnl"},
-			{"rkh", "sph"},
-		} {
-			removeEdge(e[0], e[1], cs)
+	}
+
+	if export != nil {
+		if err := export.Write(Graph(cs, cut)); err != nil {
+			log.Fatal(err)
 		}
 	}
 
+	for _, e := range cut {
+		removeEdge(e[0], e[1], cs)
+	}
+
 	val := productOfTwoComponents(cs)
 
 	slog.Info("Finished Day TwentyFive part one", "expected", expected, "val", val)
@@ -232,8 +184,12 @@ var Cmd = &cobra.Command{
 	Use: "dayTwentyFive",
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
+		export, err := graph.FromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
 		if !cmd.Flag("part-two").Changed {
-			partOne(puzzleInput)
+			partOne(puzzleInput, export)
 		} else {
 			partTwo(puzzleInput)
 		}
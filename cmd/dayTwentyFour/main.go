@@ -1,6 +1,7 @@
 package dayTwentyFour
 
 import (
+	"adventofcode/cmd/artifacts"
 	"bufio"
 	"bytes"
 	"fmt"
@@ -91,7 +92,7 @@ print(intersections)
 		return "", err
 	}
 
-	tempFile, err := os.CreateTemp("/tmp", "hailstones_*.py")
+	tempFile, err := artifacts.Temp("hailstones_*.py")
 	if err != nil {
 		return "", err
 	}
@@ -201,7 +202,7 @@ print(solution[0][x] + solution[0][y] + solution[0][z])
 		return "", err
 	}
 
-	tempFile, err := os.CreateTemp("/tmp", "hailstones_*.py")
+	tempFile, err := artifacts.Temp("hailstones_*.py")
 	if err != nil {
 		return "", err
 	}
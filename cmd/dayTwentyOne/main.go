@@ -3,8 +3,12 @@ package dayTwentyOne
 import (
 	"adventofcode/cmd/coordinates"
 	"adventofcode/cmd/fileReader"
+	"adventofcode/cmd/render"
+	"adventofcode/cmd/runs"
+	"log"
 	"log/slog"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -30,16 +34,28 @@ type Step struct {
 	StepNumber int
 }
 
-func ReachablePlots(g []string, start *coordinates.Coordinate, steps int) int {
+func ReachablePlots(g []string, start *coordinates.Coordinate, steps int, recorder *render.Recorder) map[coordinates.Coordinate]bool {
 	curs := []*Step{{start, 0}}
-	seen := map[string]bool{}
-	finalPlots := map[string]bool{}
+	seen := map[string]bool{start.String(): true}
+	firstReached := []int{1}
+	finalPlots := map[coordinates.Coordinate]bool{}
+	reached := render.Visited{}
+	lastStep := -1
 	for len(curs) > 0 {
 		/**
 		we'll grab next positions and potentially add them to curs to step from
 		all positions can be final by
 		**/
 		next := curs[0]
+		if next.StepNumber != lastStep && recorder != nil {
+			// the queue only holds this step's positions when we first pop one
+			frontier := render.Points{}
+			for _, c := range curs {
+				frontier = append(frontier, *c.Pos)
+			}
+			recorder.Frame(g, slices.Clone(reached), frontier)
+			lastStep = next.StepNumber
+		}
 		curs = curs[1:]
 		nextStepNumber := next.StepNumber + 1
 		if nextStepNumber > steps {
@@ -48,20 +64,44 @@ func ReachablePlots(g []string, start *coordinates.Coordinate, steps int) int {
 		for _, n := range NextPositions(g, next.Pos) {
 			if _, ok := seen[n.String()]; !ok {
 				seen[n.String()] = true
+				reached = append(reached, *n)
 				curs = append(curs, &Step{n, nextStepNumber})
+				firstReached = countReached(firstReached, nextStepNumber)
 			}
 			// Can only enter a plot at the end if we're at the step count or have an even number of steps left
 			if nextStepNumber == steps || (steps-nextStepNumber)%2 == 0 {
-				finalPlots[n.String()] = true
+				finalPlots[*n] = true
 			}
 		}
 	}
 
 	PrintGrid(g, finalPlots)
-	return len(finalPlots)
+	observeReachable(firstReached, steps)
+	return finalPlots
+}
+
+func countReached(firstReached []int, step int) []int {
+	for len(firstReached) <= step {
+		firstReached = append(firstReached, 0)
+	}
+	firstReached[step]++
+	return firstReached
+}
+
+// observeReachable works out how many plots can be ended on after each number of steps, a plot
+// first reached in d steps can be ended on in any later step count with the same parity. The
+// curve is what the part two extrapolation fits.
+func observeReachable(firstReached []int, steps int) {
+	byParity := [2]int{}
+	for s := 0; s <= steps; s++ {
+		if s < len(firstReached) {
+			byParity[s%2] += firstReached[s]
+		}
+		runs.Observe("reachable plots", float64(s), float64(byParity[s%2]))
+	}
 }
 
-func PrintGrid(g []string, plots map[string]bool) {
+func PrintGrid(g []string, plots map[coordinates.Coordinate]bool) {
 	if strings.ToLower(os.Getenv("LOG_LEVEL")) != "debug" {
 		return
 	}
@@ -69,7 +109,7 @@ func PrintGrid(g []string, plots map[string]bool) {
 		for j, c := range row {
 			if c == '#' {
 				print("#")
-			} else if _, ok := plots[(&coordinates.Coordinate{Row: i, Col: j}).String()]; ok {
+			} else if plots[coordinates.Coordinate{Row: i, Col: j}] {
 				print("O")
 			} else if c == 'S' {
 				print("S")
@@ -81,7 +121,38 @@ func PrintGrid(g []string, plots map[string]bool) {
 	}
 }
 
-func partOne(puzzleFile string, stepCount int) {
+// renderPlots draws the reachable plots, repeating the garden out as far as they reach.
+func renderPlots(g []string, plots map[coordinates.Coordinate]bool, request *render.Request) {
+	if request == nil {
+		return
+	}
+	minRow, maxRow, minCol, maxCol := 0, len(g)-1, 0, len(g[0])-1
+	for p := range plots {
+		minRow, maxRow = min(minRow, p.Row), max(maxRow, p.Row)
+		minCol, maxCol = min(minCol, p.Col), max(maxCol, p.Col)
+	}
+	wrap := func(i, size int) int {
+		return ((i % size) + size) % size
+	}
+
+	rows := []string{}
+	for row := minRow; row <= maxRow; row++ {
+		b := strings.Builder{}
+		for col := minCol; col <= maxCol; col++ {
+			b.WriteByte(g[wrap(row, len(g))][wrap(col, len(g[0]))])
+		}
+		rows = append(rows, b.String())
+	}
+	reached := render.Visited{}
+	for p := range plots {
+		reached = append(reached, coordinates.Coordinate{Row: p.Row - minRow, Col: p.Col - minCol})
+	}
+	if err := request.Write(rows, reached); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func partOne(puzzleFile string, stepCount int, request *render.Request, recorder *render.Recorder) {
 	slog.Info("Day TwentyOne part one", "puzzle file", puzzleFile)
 	g := strings.Split(fileReader.ReadFileContents(puzzleFile), "\n")
 
@@ -95,9 +166,13 @@ func partOne(puzzleFile string, stepCount int) {
 		}
 	}
 
-	plots := ReachablePlots(g, start, stepCount)
+	plots := ReachablePlots(g, start, stepCount, recorder)
+	renderPlots(g, plots, request)
+	if err := recorder.Save(); err != nil {
+		log.Fatal(err)
+	}
 
-	slog.Info("Day TwentyOne part one", "reachable plots", plots)
+	slog.Info("Day TwentyOne part one", "reachable plots", len(plots))
 }
 
 func NextInfinitePositions(g []string, c *coordinates.Coordinate) []*coordinates.Coordinate {
@@ -133,7 +208,7 @@ I ran mine on several points and got the following polynomial:
 14888*x^2/17161 + 26154*x/17161 − 213738/17161
 *
 */
-func partTwo(puzzleFile string, steps int) {
+func partTwo(puzzleFile string, steps int, request *render.Request) {
 	slog.Info("Day TwentyOne part two", "puzzle file", puzzleFile)
 	g := strings.Split(fileReader.ReadFileContents(puzzleFile), "\n")
 
@@ -148,8 +223,9 @@ func partTwo(puzzleFile string, steps int) {
 	}
 
 	curs := []*Step{{start, 0}}
-	seen := map[string]bool{}
-	finalPlots := map[string]bool{}
+	seen := map[string]bool{start.String(): true}
+	firstReached := []int{1}
+	finalPlots := map[coordinates.Coordinate]bool{}
 	for len(curs) > 0 {
 		/**
 		we'll grab next positions and potentially add them to curs to step from
@@ -165,15 +241,18 @@ func partTwo(puzzleFile string, steps int) {
 			if _, ok := seen[n.String()]; !ok {
 				seen[n.String()] = true
 				curs = append(curs, &Step{n, nextStepNumber})
+				firstReached = countReached(firstReached, nextStepNumber)
 			}
 			// Can only enter a plot at the end if we're at the step count or have an even number of steps left
 			if nextStepNumber == steps || (steps-nextStepNumber)%2 == 0 {
-				finalPlots[n.String()] = true
+				finalPlots[*n] = true
 			}
 		}
 	}
 
 	PrintGrid(g, finalPlots)
+	observeReachable(firstReached, steps)
+	renderPlots(g, finalPlots, request)
 
 	slog.Info("Day TwentyOne part two", "reachable plots", len(finalPlots))
 }
@@ -183,10 +262,18 @@ var Cmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
 		stepCount, _ := cmd.Flags().GetInt("step-count")
+		request, err := render.FromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
 		if !cmd.Flag("part-two").Changed {
-			partOne(puzzleInput, stepCount)
+			recorder, err := render.RecorderFromFlags(cmd)
+			if err != nil {
+				log.Fatal(err)
+			}
+			partOne(puzzleInput, stepCount, request, recorder)
 		} else {
-			partTwo(puzzleInput, stepCount)
+			partTwo(puzzleInput, stepCount, request)
 		}
 	},
 }
@@ -1,12 +1,16 @@
 package dayTwentyThree
 
 import (
+	"adventofcode/cmd/artifacts"
+	"adventofcode/cmd/coordinates"
 	"adventofcode/cmd/fileReader"
+	"adventofcode/cmd/graph"
+	"adventofcode/cmd/render"
 	"adventofcode/cmd/util"
 	"container/heap"
 	"fmt"
+	"log"
 	"log/slog"
-	"os"
 	"slices"
 	"strings"
 
@@ -196,7 +200,7 @@ func AStarSearch(grid [][]string,
 				}
 				dbgCellsOut = append(dbgCellsOut, []byte("\n")...)
 			}
-			os.WriteFile("/tmp/dbgCells.txt", dbgCellsOut, 0644)
+			artifacts.Write("cells.txt", dbgCellsOut)
 			return ReconstructPath(cameFrom, current), current
 		}
 
@@ -240,15 +244,25 @@ func PrintPath(path []*Cell, grid []string) {
 		dir := "O"
 		grid[row] = grid[row][:col] + dir + grid[row][col+1:]
 	}
-	os.WriteFile("/tmp/grid.txt", []byte(strings.Join(grid, "\n")), 0644)
+	artifacts.Write("grid.txt", []byte(strings.Join(grid, "\n")))
 	niceCellPath := []string{}
 	for _, c := range path {
 		niceCellPath = append(niceCellPath, c.CellState())
 	}
-	os.WriteFile("/tmp/path.txt", []byte(strings.Join(niceCellPath, "\n")), 0644)
+	artifacts.Write("path.txt", []byte(strings.Join(niceCellPath, "\n")))
 }
 
-func partOne(puzzleFile string) {
+func renderPath(path []*Cell, rows []string, request *render.Request) {
+	cells := render.Path{}
+	for _, c := range path {
+		cells = append(cells, coordinates.Coordinate{Row: c.coords.Row, Col: c.coords.Col})
+	}
+	if err := request.Write(rows, cells); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func partOne(puzzleFile string, request *render.Request) {
 	slog.Info("Day TwentyThree part one", "puzzle file", puzzleFile)
 
 	rows := strings.Split(fileReader.ReadFileContents(puzzleFile), "\n")
@@ -269,6 +283,7 @@ func partOne(puzzleFile string) {
 		return c.coords.Equals(d)
 	})
 
+	renderPath(path, rows, request)
 	PrintPath(path, rows)
 
 	slog.Info("Day TwentyThree part one", "expected", expected, "distance", -finalCell.g)
@@ -474,10 +489,66 @@ func PrintGraph(path []*Node, grid []string) {
 		col := n.Col
 		grid[row] = grid[row][:col] + "X" + grid[row][col+1:]
 	}
-	os.WriteFile("/tmp/grid.txt", []byte(strings.Join(grid, "\n")), 0644)
+	artifacts.Write("grid.txt", []byte(strings.Join(grid, "\n")))
 }
 
-func partTwo(puzzleFile string) {
+// renderGraph shades the junctions the longest hike passes through.
+func renderGraph(path []*Node, rows []string, request *render.Request) {
+	junctions := render.Visited{}
+	for _, n := range path {
+		junctions = append(junctions, coordinates.Coordinate{Row: n.Row, Col: n.Col})
+	}
+	if err := request.Write(rows, junctions); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Graph is the trail network between junctions with hike lengths on the edges, the longest
+// hike highlighted. Junctions that graphify folded away are left out and where two trails
+// join the same junctions only the longer is kept.
+func Graph(nodes map[string]*Node, path []*Node) *graph.Graph {
+	names := []string{}
+	for name, n := range nodes {
+		if len(n.Edges) > 0 {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	g := graph.New("trails", false)
+	for _, name := range names {
+		g.AddNode(name, name, graph.Circle)
+	}
+	longest := map[[2]string]int{}
+	for _, name := range names {
+		for _, e := range nodes[name].Edges {
+			pair := [2]string{name, e.Node.String()}
+			if pair[0] > pair[1] {
+				pair[0], pair[1] = pair[1], pair[0]
+			}
+			longest[pair] = max(longest[pair], e.Dist)
+		}
+	}
+	pairs := [][2]string{}
+	for pair := range longest {
+		pairs = append(pairs, pair)
+	}
+	slices.SortFunc(pairs, func(a, b [2]string) int {
+		return strings.Compare(a[0]+" "+a[1], b[0]+" "+b[1])
+	})
+	for _, pair := range pairs {
+		g.AddEdge(pair[0], pair[1], fmt.Sprint(longest[pair]))
+	}
+
+	hike := []string{}
+	for _, n := range path {
+		hike = append(hike, n.String())
+	}
+	g.HighlightPath(hike...)
+	return g
+}
+
+func partTwo(puzzleFile string, request *render.Request, export *graph.Request) {
 	slog.Info("Day TwentyThree part two", "puzzle file", puzzleFile)
 
 	rows := strings.Split(fileReader.ReadFileContents(puzzleFile), "\n")
@@ -504,6 +575,12 @@ func partTwo(puzzleFile string) {
 	end := findOnlySlot(grid, len(grid)-1)
 	distance, path := DFS(startNode, end, "")
 
+	renderGraph(path, rows, request)
+	if export != nil {
+		if err := export.Write(Graph(nodes, path)); err != nil {
+			log.Fatal(err)
+		}
+	}
 	PrintGraph(path, rows)
 
 	slog.Info("Day TwentyThree part two", "expected", expected, "distance", distance)
@@ -513,10 +590,18 @@ var Cmd = &cobra.Command{
 	Use: "dayTwentyThree",
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
+		request, err := render.FromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
+		export, err := graph.FromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
 		if !cmd.Flag("part-two").Changed {
-			partOne(puzzleInput)
+			partOne(puzzleInput, request)
 		} else {
-			partTwo(puzzleInput)
+			partTwo(puzzleInput, request, export)
 		}
 	},
 }
@@ -1,12 +1,14 @@
 package dayTwentyTwo
 
 import (
+	"adventofcode/cmd/artifacts"
+	"adventofcode/cmd/coordinates"
 	"adventofcode/cmd/fileReader"
+	"adventofcode/cmd/render"
 	"adventofcode/cmd/util"
 	"fmt"
+	"log"
 	"log/slog"
-	"math"
-	"os"
 	"slices"
 	"strings"
 
@@ -27,23 +29,33 @@ func (c *Coordinate) String() string {
 
 type Brick struct {
 	Coords      []*Coordinate `@@ Tilde @@`
-	SupportedBy []string
-	Supporting  []string
+	SupportedBy []*Brick
+	Supporting  []*Brick
 	Id          string
+	// Position in the settled, bottom up ordering of bricks
+	index int
+}
+
+func brickIds(bricks []*Brick) []string {
+	ids := make([]string, len(bricks))
+	for i, b := range bricks {
+		ids[i] = b.Id
+	}
+	return ids
 }
 
 func (b *Brick) String() string {
-	return fmt.Sprintf("%s(%v): supported by: %v supports: %v", b.Id, b.Coords, b.SupportedBy, b.Supporting)
+	return fmt.Sprintf("%s(%v): supported by: %v supports: %v", b.Id, b.Coords, brickIds(b.SupportedBy), brickIds(b.Supporting))
 }
 
 func (b *Brick) FinishInit(id int) {
 	b.Id = ""
 	for id >= 0 {
-		b.Id += string('A' + (id % 26))
+		b.Id += string(rune('A' + (id % 26)))
 		id -= 26
 	}
-	b.SupportedBy = []string{}
-	b.Supporting = []string{}
+	b.SupportedBy = []*Brick{}
+	b.Supporting = []*Brick{}
 }
 
 func (b *Brick) TopZ() int {
@@ -60,43 +72,6 @@ func (b *Brick) Fall(deltaZ int) {
 	}
 }
 
-// Assume we have two line segments, s and t, return true if they overlap
-func overlap(s1, s2, t1, t2 int) bool {
-	sContains := s1 <= t1 && t2 <= s2
-	tContains := t1 <= s1 && s2 <= t2
-	overlapLeft := s1 <= t1 && t1 <= s2
-	overlapRight := s1 <= t2 && t2 <= s2
-	return sContains || tContains || overlapLeft || overlapRight
-}
-
-func overlapX(a1, a2, b1, b2 *Coordinate) bool {
-	aL, aR := util.Order(a1.X, a2.X)
-	bL, bR := util.Order(b1.X, b2.X)
-	return overlap(aL, aR, bL, bR)
-}
-
-func overlapY(a1, a2, b1, b2 *Coordinate) bool {
-	aL, aR := util.Order(a1.Y, a2.Y)
-	bL, bR := util.Order(b1.Y, b2.Y)
-	return overlap(aL, aR, bL, bR)
-}
-
-func (b *Brick) Supports(b2 *Brick) bool {
-	if b.TopZ() >= b2.BottomZ() {
-		return false
-	}
-
-	// I need to check if b has any overlap in the x,y plane with b2
-	if !overlapX(b.Coords[0], b.Coords[1], b2.Coords[0], b2.Coords[1]) {
-		return false
-	}
-	if !overlapY(b.Coords[0], b.Coords[1], b2.Coords[0], b2.Coords[1]) {
-		return false
-	}
-
-	return true
-}
-
 func ParseBricks(puzzleFile string) []*Brick {
 	lines := strings.Split(fileReader.ReadFileContents(puzzleFile), "\n")
 
@@ -132,85 +107,154 @@ func ParseBricks(puzzleFile string) []*Brick {
 	return bricks
 }
 
-func applyGravity(bricks []*Brick) []*Brick {
-	topOfBricksAtRest := map[int][]*Brick{}
+// sideView looks at the bricks along the y axis, the ground is the bottom row. The landed
+// brick, if any, is picked out.
+func sideView(bricks []*Brick, width, height int, landed *Brick) ([]string, render.Visited) {
+	rows := make([][]byte, height+1)
+	for i := range rows {
+		rows[i] = []byte(strings.Repeat(".", width))
+	}
+	rows[height] = []byte(strings.Repeat("-", width))
+
+	picked := render.Visited{}
 	for _, b := range bricks {
-		supported := false
-		for b.BottomZ() > 1 {
-			if bs, ok := topOfBricksAtRest[b.BottomZ()-1]; ok {
-				for _, b2 := range bs {
-					if b2.Supports(b) {
-						supported = true
-						break
-					}
+		x1, x2 := util.Order(b.Coords[0].X, b.Coords[1].X)
+		for x := x1; x <= x2; x++ {
+			for z := b.BottomZ(); z <= b.TopZ(); z++ {
+				rows[height-z][x] = '#'
+				if b == landed {
+					picked = append(picked, coordinates.Coordinate{Row: height - z, Col: x})
 				}
 			}
-			if supported {
-				break
-			}
-
-			b.Coords[0].Z--
-			b.Coords[1].Z--
 		}
+	}
 
-		if _, ok := topOfBricksAtRest[b.TopZ()]; !ok {
-			topOfBricksAtRest[b.TopZ()] = []*Brick{}
-		}
-		topOfBricksAtRest[b.TopZ()] = append(topOfBricksAtRest[b.TopZ()], b)
+	view := make([]string, len(rows))
+	for i, row := range rows {
+		view[i] = string(row)
+	}
+	return view, picked
+}
 
-		/**
-		for dec := i - 1; dec >= 0; dec-- {
-			b2 := bricks[j]
-			if b2.Supports(b) {
-				unsupported = false
+// settle drops the bricks, lowest first, onto a height map of the x,y plane. Each
+// cell remembers how tall the stack is there and which brick is on top, so a brick
+// lands one above the tallest cell under it and rests on whichever bricks top those
+// cells. The support DAG falls out of that for free.
+func settle(bricks []*Brick, recorder *render.Recorder) []*Brick {
+	maxX, maxY, maxZ := 0, 0, 0
+	for _, b := range bricks {
+		maxX = util.Max(maxX, util.Max(b.Coords[0].X, b.Coords[1].X))
+		maxY = util.Max(maxY, util.Max(b.Coords[0].Y, b.Coords[1].Y))
+		maxZ = util.Max(maxZ, b.TopZ())
+	}
+	record := func(landed *Brick) {
+		if recorder != nil {
+			view, picked := sideView(bricks, maxX+1, maxZ, landed)
+			recorder.Frame(view, picked)
+		}
+	}
+	record(nil)
+	heights := make([][]int, maxX+1)
+	tops := make([][]*Brick, maxX+1)
+	for x := range heights {
+		heights[x] = make([]int, maxY+1)
+		tops[x] = make([]*Brick, maxY+1)
+	}
 
-				slog.Debug("Falling", "b", b, "b2", b2)
-				b.Fall(b.BottomZ() - b2.TopZ() - 1)
+	for _, b := range bricks {
+		x1, x2 := util.Order(b.Coords[0].X, b.Coords[1].X)
+		y1, y2 := util.Order(b.Coords[0].Y, b.Coords[1].Y)
 
-				break
+		restingHeight := 0
+		for x := x1; x <= x2; x++ {
+			for y := y1; y <= y2; y++ {
+				restingHeight = util.Max(restingHeight, heights[x][y])
 			}
 		}
-
-		// unsupported bricks fall to the ground
-		if unsupported {
-			slog.Debug("Falling to ground", "b", b)
-			b.Fall(b.BottomZ() - 1)
+		b.Fall(b.BottomZ() - restingHeight - 1)
+
+		for x := x1; x <= x2; x++ {
+			for y := y1; y <= y2; y++ {
+				below := tops[x][y]
+				if below != nil && heights[x][y] == restingHeight && !slices.Contains(b.SupportedBy, below) {
+					b.SupportedBy = append(b.SupportedBy, below)
+					below.Supporting = append(below.Supporting, b)
+				}
+				heights[x][y] = b.TopZ()
+				tops[x][y] = b
+			}
 		}
-			**/
+		record(b)
 	}
 
-	slices.SortFunc[[]*Brick](bricks, func(a, b *Brick) int {
+	// Every supporter ends below what it supports, so this is also a topological order
+	slices.SortStableFunc[[]*Brick](bricks, func(a, b *Brick) int {
 		return a.BottomZ() - b.BottomZ()
 	})
+	for i, b := range bricks {
+		b.index = i
+	}
 
 	return bricks
 }
 
-func findSupports(bricks []*Brick, maxSupportsWeCareAbout int) []*Brick {
-	for i, b := range bricks {
-		for j := i - 1; j >= 0; j-- {
-			b2 := bricks[j]
-			if b2.Supports(b) {
-				if b.BottomZ()-b2.TopZ() > 1 {
-					// Although we're sorted, we can't break because tall blocks may actually be further down
-					// the list
-					continue
-				}
-
-				b.SupportedBy = append(b.SupportedBy, b2.Id)
-				b2.Supporting = append(b2.Supporting, b.Id)
-
-				// We only care about being supported by _more_ than one brick, so exit early
-				if len(b.SupportedBy) >= maxSupportsWeCareAbout {
-					slog.Debug("hit max supports", "b", b.Id, "maxSupports", maxSupportsWeCareAbout)
-					break
-				}
+// dominatorTree finds, for each brick, the closest brick whose removal alone drops it.
+// The ground is an extra node at len(bricks) that dominates everything. Bricks come in
+// topological order, so a brick's immediate dominator is the lowest common ancestor
+// of its supporters in the tree built so far.
+func dominatorTree(bricks []*Brick) (idom []int, depth []int) {
+	ground := len(bricks)
+	idom = make([]int, len(bricks)+1)
+	depth = make([]int, len(bricks)+1)
+	idom[ground] = ground
+
+	lca := func(a, b int) int {
+		for a != b {
+			if depth[a] < depth[b] {
+				a, b = b, a
 			}
+			a = idom[a]
+		}
+		return a
+	}
 
+	for _, b := range bricks {
+		dom := ground
+		for i, s := range b.SupportedBy {
+			if i == 0 {
+				dom = s.index
+			} else {
+				dom = lca(dom, s.index)
+			}
 		}
+		idom[b.index] = dom
+		depth[b.index] = depth[dom] + 1
 	}
 
-	return bricks
+	return idom, depth
+}
+
+// chainReactions counts how many other bricks fall when each brick is disintegrated,
+// the size of its dominator subtree less itself.
+func chainReactions(bricks []*Brick) []int {
+	idom, _ := dominatorTree(bricks)
+	subtreeSizes := make([]int, len(bricks)+1)
+	for i := len(bricks) - 1; i >= 0; i-- {
+		subtreeSizes[i]++
+		subtreeSizes[idom[i]] += subtreeSizes[i]
+	}
+
+	falls := make([]int, len(bricks))
+	for i := range bricks {
+		falls[i] = subtreeSizes[i] - 1
+	}
+	return falls
+}
+
+func printReactions(bricks []*Brick, falls []int) {
+	for i, b := range bricks {
+		fmt.Printf("%s %d\n", b.Id, falls[i])
+	}
 }
 
 /*
@@ -222,74 +266,55 @@ wrong answers:
 - 460
 - 407??
 */
-func partOne(puzzleFile string) {
+func partOne(puzzleFile string, report bool, recorder *render.Recorder) {
 	slog.Info("Day TwentyTwo part one", "puzzle file", puzzleFile)
 
-	bricks := ParseBricks(puzzleFile)
-	bricks = applyGravity(bricks)
-	bricks = findSupports(bricks, 2)
-
-	bricksMapped := map[string]*Brick{}
-	for _, b := range bricks {
-		bricksMapped[b.Id] = b
+	bricks := settle(ParseBricks(puzzleFile), recorder)
+	if err := recorder.Save(); err != nil {
+		log.Fatal(err)
 	}
+	falls := chainReactions(bricks)
 
 	disintegrable := []string{}
-	for _, b := range bricks {
-		canDisintegrate := true
-		for _, supportedId := range b.Supporting {
-			if len(bricksMapped[supportedId].SupportedBy) != 2 {
-				canDisintegrate = false
-				break
-			}
-		}
-
-		if canDisintegrate {
+	for i, b := range bricks {
+		if falls[i] == 0 {
 			disintegrable = append(disintegrable, b.Id)
 		}
 	}
 
+	if report {
+		printReactions(bricks, falls)
+	}
 	printBricks(bricks)
 	slog.Debug("Disintegrable", "disintegrable", disintegrable)
 	slog.Info("Disintegrable", "disintegrable", len(disintegrable))
 }
 
+// printBricks dumps every settled brick, only when debugging as there are a lot of them.
 func printBricks(bricks []*Brick) {
-	os.WriteFile("/tmp/bricks_debug.txt", []byte(fmt.Sprintf("%v", bricks)), 0644)
+	if !util.InDebugMode() {
+		return
+	}
+	artifacts.Write("bricks.txt", []byte(fmt.Sprintf("%v", bricks)))
 }
 
-func partTwo(puzzleFile string) {
+func partTwo(puzzleFile string, report bool, recorder *render.Recorder) {
 	slog.Info("Day TwentyTwo part two", "puzzle file", puzzleFile)
 
-	bricks := ParseBricks(puzzleFile)
-	bricks = applyGravity(bricks)
-	bricks = findSupports(bricks, math.MaxInt)
+	bricks := settle(ParseBricks(puzzleFile), recorder)
+	if err := recorder.Save(); err != nil {
+		log.Fatal(err)
+	}
+	falls := chainReactions(bricks)
 
 	reactionSum := 0
-	for i, b := range bricks {
-		removed := map[string]bool{b.Id: true}
-
-		for _, b2 := range bricks[i:] {
-			if b2.Coords[0].Z == 1 {
-				// Skip ground bricks
-				continue
-			}
-			supportsRemoved := 0
-			for _, supportingId := range b2.SupportedBy {
-				if _, ok := removed[supportingId]; ok {
-					supportsRemoved++
-				}
-			}
-
-			if supportsRemoved == len(b2.SupportedBy) {
-				removed[b2.Id] = true
-			}
-		}
-
-		slog.Debug("Will collapse", "b", b.Id, "willCollapse", removed)
-		reactionSum += len(removed) - 1 // account for seeding with "self"
+	for _, f := range falls {
+		reactionSum += f
 	}
 
+	if report {
+		printReactions(bricks, falls)
+	}
 	printBricks(bricks)
 	/**
 	Attempts with wrong answers:
@@ -304,14 +329,20 @@ var Cmd = &cobra.Command{
 	Use: "dayTwentyTwo",
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
+		report, _ := cmd.Flags().GetBool("report")
+		recorder, err := render.RecorderFromFlags(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
 		if !cmd.Flag("part-two").Changed {
-			partOne(puzzleInput)
+			partOne(puzzleInput, report, recorder)
 		} else {
-			partTwo(puzzleInput)
+			partTwo(puzzleInput, report, recorder)
 		}
 	},
 }
 
 func init() {
 	Cmd.Flags().Bool("part-two", false, "Whether to run part two of the day's challenge")
+	Cmd.Flags().Bool("report", false, "Print how many other bricks fall when each brick is removed")
 }
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
 
 	"github.com/alecthomas/participle/v2"
 	"github.com/spf13/cobra"
@@ -22,7 +24,7 @@ type Round struct {
 
 type Dice struct {
 	Count int    ` @Int`
-	Color string ` @("blue" | "red" | "green")`
+	Color string ` @Ident`
 }
 
 func (g *Game) String() string {
@@ -34,53 +36,77 @@ func (g *Game) String() string {
 	return string(s)
 }
 
-func (g *Game) isValid(maxBlue, maxRed, maxGreen int) bool {
-	for _, disp := range g.Rounds {
-		for _, d := range disp.Dice {
-			switch d.Color {
-			case "blue":
-				if d.Count > maxBlue {
-					return false
-				}
-			case "red":
-				if d.Count > maxRed {
-					return false
-				}
-			case "green":
-				if d.Count > maxGreen {
-					return false
-				}
+// MinimalBag is the fewest dice of each colour that could have played the game.
+func (g *Game) MinimalBag() map[string]int {
+	bag := map[string]int{}
+	for _, r := range g.Rounds {
+		for _, d := range r.Dice {
+			if d.Count > bag[d.Color] {
+				bag[d.Color] = d.Count
 			}
 		}
 	}
-	return true
+	return bag
 }
 
-func (g *Game) power() int {
-	var minBlue, minRed, minGreen int
-	for _, r := range g.Rounds {
-		for _, d := range r.Dice {
-			switch d.Color {
-			case "blue":
-				if d.Count > minBlue {
-					minBlue = d.Count
-				}
-			case "red":
-				if d.Count > minRed {
-					minRed = d.Count
-				}
-			case "green":
-				if d.Count > minGreen {
-					minGreen = d.Count
-				}
-			}
+// failingColors lists, in order, the colours the game draws more of than the limits
+// allow. Colours without a limit can't be drawn at all.
+func (g *Game) failingColors(limits map[string]int) []string {
+	failing := []string{}
+	for color, count := range g.MinimalBag() {
+		if count > limits[color] {
+			failing = append(failing, color)
 		}
 	}
-	return minBlue * minRed * minGreen
+	slices.Sort(failing)
+	return failing
+}
+
+func (g *Game) isValid(limits map[string]int) bool {
+	return len(g.failingColors(limits)) == 0
+}
+
+// power multiplies the minimal bag over every limited or drawn colour, so a limited
+// colour the game never draws zeroes it out.
+func (g *Game) power(limits map[string]int) int {
+	bag := g.MinimalBag()
+	for color := range limits {
+		if _, ok := bag[color]; !ok {
+			bag[color] = 0
+		}
+	}
+
+	power := 1
+	for _, count := range bag {
+		power *= count
+	}
+	return power
 }
 
-func partOne(puzzleFile string) {
-	fmt.Println("Day template part one", puzzleFile)
+func sortedColors(bag map[string]int) []string {
+	colors := []string{}
+	for color := range bag {
+		colors = append(colors, color)
+	}
+	slices.Sort(colors)
+	return colors
+}
+
+func printReport(game *Game, limits map[string]int) {
+	bag := game.MinimalBag()
+	parts := []string{}
+	for _, color := range sortedColors(bag) {
+		parts = append(parts, fmt.Sprintf("%s=%d", color, bag[color]))
+	}
+	line := fmt.Sprintf("Game %d: minimal bag %s, power %d", game.Id, strings.Join(parts, ","), game.power(limits))
+	if failing := game.failingColors(limits); len(failing) > 0 {
+		line += fmt.Sprintf(", invalid on %s", strings.Join(failing, ","))
+	}
+	fmt.Println(line)
+}
+
+func partOne(puzzleFile string, limits map[string]int, report bool) {
+	fmt.Println("Day two part one", puzzleFile, limits)
 	parser, err := participle.Build[Game]()
 	if err != nil {
 		log.Fatal(err)
@@ -93,8 +119,11 @@ func partOne(puzzleFile string) {
 
 	for scanner.Scan() {
 		game := scanner.Struct()
+		if report {
+			printReport(game, limits)
+		}
 
-		if game.isValid(14, 12, 13) {
+		if game.isValid(limits) {
 			validGames = append(validGames, game)
 			cumValidIdSum += game.Id
 		}
@@ -105,7 +134,7 @@ func partOne(puzzleFile string) {
 	fmt.Println(len(validGames), cumValidIdSum)
 }
 
-func partTwo(puzzleFile string) {
+func partTwo(puzzleFile string, limits map[string]int, report bool) {
 	parser, err := participle.Build[Game]()
 	if err != nil {
 		log.Fatal(err)
@@ -116,7 +145,11 @@ func partTwo(puzzleFile string) {
 	cumPowers := 0
 
 	for scanner.Scan() {
-		cumPowers += scanner.Struct().power()
+		game := scanner.Struct()
+		if report {
+			printReport(game, limits)
+		}
+		cumPowers += game.power(limits)
 	}
 
 	fmt.Println(cumPowers)
@@ -127,10 +160,17 @@ var Cmd = &cobra.Command{
 	Use: "dayTwo",
 	Run: func(cmd *cobra.Command, args []string) {
 		puzzleInput, _ := cmd.Flags().GetString("puzzle-input")
+		limits, _ := cmd.Flags().GetStringToInt("limit")
+		report, _ := cmd.Flags().GetBool("report")
 		if !cmd.Flag("part-two").Changed {
-			partOne(puzzleInput)
+			partOne(puzzleInput, limits, report)
 		} else {
-			partTwo(puzzleInput)
+			partTwo(puzzleInput, limits, report)
 		}
 	},
 }
+
+func init() {
+	Cmd.Flags().StringToInt("limit", map[string]int{"red": 12, "green": 13, "blue": 14}, "Most dice of each colour in the bag")
+	Cmd.Flags().Bool("report", false, "Print each game's minimal bag and the colours that make it invalid")
+}
@@ -0,0 +1,262 @@
+package graph
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+)
+
+// Point is where a node's centre is drawn.
+type Point struct {
+	X, Y float64
+}
+
+// Layout places every node of a graph.
+type Layout func(g *Graph) map[string]Point
+
+var Layouts = map[string]Layout{
+	"auto":    Auto,
+	"force":   Force,
+	"layered": Layered,
+}
+
+const (
+	// spacing is the length force directed edges settle at and the gap between layered nodes
+	spacing = 80.0
+	// forceIterations cool the layout from a tenth of its width down to nothing
+	forceIterations = 300
+)
+
+// Auto lays directed graphs out in layers and everything else with forces.
+func Auto(g *Graph) map[string]Point {
+	if g.Directed {
+		return Layered(g)
+	}
+	return Force(g)
+}
+
+// Force is a Fruchterman-Reingold layout: every pair of nodes pushes apart, edges pull their
+// ends together and the distance nodes may move shrinks each round. Repulsion only counts
+// nodes in neighbouring cells of a grid so big graphs like dayTwentyFive stay quick. It starts
+// from a fixed seed so the same graph always comes out the same.
+func Force(g *Graph) map[string]Point {
+	n := len(g.Nodes)
+	index := map[string]int{}
+	for i, node := range g.Nodes {
+		index[node.ID] = i
+	}
+	side := spacing * math.Sqrt(float64(max(n, 1))) * 2
+	random := rand.New(rand.NewSource(1))
+	pos := make([]Point, n)
+	for i := range pos {
+		pos[i] = Point{random.Float64() * side, random.Float64() * side}
+	}
+
+	k := spacing
+	cell := 2 * k
+	type bucket struct{ x, y int }
+	for round := 0; round < forceIterations; round++ {
+		temperature := side / 10 * (1 - float64(round)/forceIterations)
+		disp := make([]Point, n)
+
+		grid := map[bucket][]int{}
+		for i, p := range pos {
+			b := bucket{int(math.Floor(p.X / cell)), int(math.Floor(p.Y / cell))}
+			grid[b] = append(grid[b], i)
+		}
+		for i, p := range pos {
+			b := bucket{int(math.Floor(p.X / cell)), int(math.Floor(p.Y / cell))}
+			for dx := -1; dx <= 1; dx++ {
+				for dy := -1; dy <= 1; dy++ {
+					for _, j := range grid[bucket{b.x + dx, b.y + dy}] {
+						if i == j {
+							continue
+						}
+						x, y := p.X-pos[j].X, p.Y-pos[j].Y
+						d := math.Hypot(x, y)
+						if d == 0 {
+							// nudge nodes sitting on top of each other apart
+							x, y, d = float64(i-j), 1, math.Hypot(float64(i-j), 1)
+						}
+						if d > cell {
+							continue
+						}
+						push := k * k / d
+						disp[i].X += x / d * push
+						disp[i].Y += y / d * push
+					}
+				}
+			}
+		}
+
+		for _, e := range g.Edges {
+			a, b := index[e.From], index[e.To]
+			if a == b {
+				continue
+			}
+			x, y := pos[a].X-pos[b].X, pos[a].Y-pos[b].Y
+			d := max(math.Hypot(x, y), 0.01)
+			pull := d * d / k
+			disp[a].X -= x / d * pull
+			disp[a].Y -= y / d * pull
+			disp[b].X += x / d * pull
+			disp[b].Y += y / d * pull
+		}
+
+		for i := range pos {
+			d := math.Hypot(disp[i].X, disp[i].Y)
+			if d == 0 {
+				continue
+			}
+			step := min(d, temperature)
+			pos[i].X += disp[i].X / d * step
+			pos[i].Y += disp[i].Y / d * step
+		}
+	}
+
+	placed := map[string]Point{}
+	for i, node := range g.Nodes {
+		placed[node.ID] = pos[i]
+	}
+	return placed
+}
+
+// Layered is a simple Sugiyama layout. Edges closing a cycle are turned round so the graph is
+// acyclic, each node goes one layer below the deepest node pointing at it and then a few
+// sweeps order each layer by the average position of its neighbours to untangle the edges.
+func Layered(g *Graph) map[string]Point {
+	out := map[string][]string{}
+	in := map[string]int{}
+	for _, e := range g.Edges {
+		if e.From == e.To {
+			continue
+		}
+		out[e.From] = append(out[e.From], e.To)
+		in[e.To]++
+	}
+
+	// depth first from the sources, then anything left, an edge back onto the stack closes a cycle
+	forward := map[string][]string{}
+	state := map[string]int{}
+	const (
+		onStack = 1
+		done    = 2
+	)
+	var visit func(id string)
+	visit = func(id string) {
+		state[id] = onStack
+		for _, to := range out[id] {
+			switch state[to] {
+			case onStack:
+				forward[to] = append(forward[to], id)
+			case done:
+				forward[id] = append(forward[id], to)
+			default:
+				forward[id] = append(forward[id], to)
+				visit(to)
+			}
+		}
+		state[id] = done
+	}
+	for _, sources := range []bool{true, false} {
+		for _, node := range g.Nodes {
+			if state[node.ID] == 0 && (in[node.ID] == 0) == sources {
+				visit(node.ID)
+			}
+		}
+	}
+
+	// longest path layering, walking the nodes in topological order
+	parents := map[string][]string{}
+	pending := map[string]int{}
+	for from, tos := range forward {
+		for _, to := range tos {
+			parents[to] = append(parents[to], from)
+			pending[to]++
+		}
+	}
+	layer := map[string]int{}
+	queue := []string{}
+	for _, node := range g.Nodes {
+		if pending[node.ID] == 0 {
+			queue = append(queue, node.ID)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, to := range forward[id] {
+			layer[to] = max(layer[to], layer[id]+1)
+			if pending[to]--; pending[to] == 0 {
+				queue = append(queue, to)
+			}
+		}
+	}
+
+	layers := [][]string{}
+	for _, node := range g.Nodes {
+		l := layer[node.ID]
+		for len(layers) <= l {
+			layers = append(layers, nil)
+		}
+		layers[l] = append(layers[l], node.ID)
+	}
+
+	order := map[string]float64{}
+	for _, ids := range layers {
+		for i, id := range ids {
+			order[id] = float64(i)
+		}
+	}
+	// neighbours looks up the layers above on the way down and the ones below on the way up
+	above := parents
+	below := forward
+	for sweep := 0; sweep < 8; sweep++ {
+		neighbours, ls := above, layers
+		if sweep%2 == 1 {
+			neighbours, ls = below, slices.Clone(layers)
+			slices.Reverse(ls)
+		}
+		for _, ids := range ls {
+			barycentre := map[string]float64{}
+			for _, id := range ids {
+				barycentre[id] = order[id]
+				if len(neighbours[id]) == 0 {
+					continue
+				}
+				sum := 0.0
+				for _, nb := range neighbours[id] {
+					sum += order[nb]
+				}
+				barycentre[id] = sum / float64(len(neighbours[id]))
+			}
+			slices.SortStableFunc(ids, func(a, b string) int {
+				switch {
+				case barycentre[a] < barycentre[b]:
+					return -1
+				case barycentre[a] > barycentre[b]:
+					return 1
+				default:
+					return 0
+				}
+			})
+			for i, id := range ids {
+				order[id] = float64(i)
+			}
+		}
+	}
+
+	widest := 0
+	for _, ids := range layers {
+		widest = max(widest, len(ids))
+	}
+	placed := map[string]Point{}
+	for l, ids := range layers {
+		// centre each layer under the widest one
+		offset := float64(widest-len(ids)) / 2
+		for i, id := range ids {
+			placed[id] = Point{(offset + float64(i)) * spacing * 1.5, float64(l) * spacing}
+		}
+	}
+	return placed
+}
@@ -0,0 +1,373 @@
+package graph
+
+import (
+	"adventofcode/cmd/artifacts"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Shape is how a node is drawn, the empty shape leaves it to each format's default.
+type Shape string
+
+const (
+	Box          Shape = "box"
+	Rounded      Shape = "rounded"
+	Circle       Shape = "circle"
+	DoubleCircle Shape = "doublecircle"
+	Diamond      Shape = "diamond"
+	Hexagon      Shape = "hexagon"
+)
+
+type Node struct {
+	ID    string
+	Label string
+	Shape Shape
+	// Highlight picks the node out, like the junctions on a longest path
+	Highlight bool
+}
+
+type Edge struct {
+	From, To  string
+	Label     string
+	Highlight bool
+}
+
+// Graph is what every day hands over to be exported. Nodes and edges are written in the
+// order they were added so the same input always exports the same file.
+type Graph struct {
+	Name     string
+	Directed bool
+	Nodes    []*Node
+	Edges    []*Edge
+	nodes    map[string]*Node
+}
+
+func New(name string, directed bool) *Graph {
+	return &Graph{Name: name, Directed: directed, nodes: map[string]*Node{}}
+}
+
+// AddNode adds the node if it is new, otherwise it updates the label and shape of the one
+// already there.
+func (g *Graph) AddNode(id, label string, shape Shape) *Node {
+	if n, ok := g.nodes[id]; ok {
+		n.Label, n.Shape = label, shape
+		return n
+	}
+	n := &Node{ID: id, Label: label, Shape: shape}
+	g.nodes[id] = n
+	g.Nodes = append(g.Nodes, n)
+	return n
+}
+
+func (g *Graph) Node(id string) *Node {
+	return g.nodes[id]
+}
+
+// AddEdge joins two nodes, adding either of them with its id as the label if it's missing.
+func (g *Graph) AddEdge(from, to, label string) *Edge {
+	for _, id := range []string{from, to} {
+		if _, ok := g.nodes[id]; !ok {
+			g.AddNode(id, id, "")
+		}
+	}
+	e := &Edge{From: from, To: to, Label: label}
+	g.Edges = append(g.Edges, e)
+	return e
+}
+
+// joins reports whether the edge runs between the two nodes, either way round when the
+// graph is undirected.
+func (g *Graph) joins(e *Edge, from, to string) bool {
+	if e.From == from && e.To == to {
+		return true
+	}
+	return !g.Directed && e.From == to && e.To == from
+}
+
+// HighlightEdge picks out every edge between the two nodes, like the wires of a cut.
+func (g *Graph) HighlightEdge(from, to string) {
+	for _, e := range g.Edges {
+		if g.joins(e, from, to) {
+			e.Highlight = true
+		}
+	}
+}
+
+// HighlightPath picks out each node in turn and the edges taken between them.
+func (g *Graph) HighlightPath(ids ...string) {
+	for i, id := range ids {
+		if n, ok := g.nodes[id]; ok {
+			n.Highlight = true
+		}
+		if i > 0 {
+			g.HighlightEdge(ids[i-1], id)
+		}
+	}
+}
+
+// DOT writes the graph for graphviz, highlights are drawn thick and red.
+func (g *Graph) DOT() string {
+	b := strings.Builder{}
+	kind, arrow := "graph", "--"
+	if g.Directed {
+		kind, arrow = "digraph", "->"
+	}
+	fmt.Fprintf(&b, "%s %q {\n", kind, g.Name)
+	for _, n := range g.Nodes {
+		attrs := []string{fmt.Sprintf("label=%q", n.Label)}
+		switch n.Shape {
+		case "":
+		case Rounded:
+			attrs = append(attrs, "shape=box", "style=rounded")
+		default:
+			attrs = append(attrs, "shape="+string(n.Shape))
+		}
+		if n.Highlight {
+			attrs = append(attrs, "color=red", "penwidth=3")
+		}
+		fmt.Fprintf(&b, "  %q [%s];\n", n.ID, strings.Join(attrs, " "))
+	}
+	for _, e := range g.Edges {
+		attrs := []string{}
+		if e.Label != "" {
+			attrs = append(attrs, fmt.Sprintf("label=%q", e.Label))
+		}
+		if e.Highlight {
+			attrs = append(attrs, "color=red", "penwidth=3")
+		}
+		fmt.Fprintf(&b, "  %q %s %q", e.From, arrow, e.To)
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(attrs, " "))
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+var mermaidShapes = map[Shape][2]string{
+	"":           {"[", "]"},
+	Box:          {"[", "]"},
+	Rounded:      {"(", ")"},
+	Circle:       {"((", "))"},
+	DoubleCircle: {"(((", ")))"},
+	Diamond:      {"{", "}"},
+	Hexagon:      {"{{", "}}"},
+}
+
+// Mermaid writes the graph as a flowchart. Mermaid is picky about ids so nodes are numbered
+// and their ids only kept in the labels, highlighted edges are drawn thick.
+func (g *Graph) Mermaid() string {
+	ids := map[string]string{}
+	for i, n := range g.Nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+	}
+
+	b := strings.Builder{}
+	b.WriteString("flowchart TD\n")
+	highlighted := []string{}
+	for _, n := range g.Nodes {
+		shape := mermaidShapes[n.Shape]
+		label := strings.ReplaceAll(n.Label, `"`, "#quot;")
+		fmt.Fprintf(&b, "  %s%s\"%s\"%s\n", ids[n.ID], shape[0], label, shape[1])
+		if n.Highlight {
+			highlighted = append(highlighted, ids[n.ID])
+		}
+	}
+	for _, e := range g.Edges {
+		link := "---"
+		switch {
+		case g.Directed && e.Highlight:
+			link = "==>"
+		case g.Directed:
+			link = "-->"
+		case e.Highlight:
+			link = "==="
+		}
+		if e.Label != "" {
+			link += "|" + strings.ReplaceAll(e.Label, "|", "#124;") + "|"
+		}
+		fmt.Fprintf(&b, "  %s %s %s\n", ids[e.From], link, ids[e.To])
+	}
+	if len(highlighted) > 0 {
+		b.WriteString("  classDef highlight stroke:#f00,stroke-width:3px\n")
+		fmt.Fprintf(&b, "  class %s highlight\n", strings.Join(highlighted, ","))
+	}
+	return b.String()
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+func highlightData(highlight bool) []graphMLData {
+	if !highlight {
+		return nil
+	}
+	return []graphMLData{{"highlight", "true"}}
+}
+
+// GraphML writes the graph for tools like yEd or Gephi, labels, shapes and highlights are
+// kept as data on each node and edge.
+func (g *Graph) GraphML() (string, error) {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{"label", "all", "label", "string"},
+			{"shape", "node", "shape", "string"},
+			{"highlight", "all", "highlight", "boolean"},
+		},
+	}
+	doc.Graph.ID = g.Name
+	doc.Graph.EdgeDefault = "undirected"
+	if g.Directed {
+		doc.Graph.EdgeDefault = "directed"
+	}
+	for _, n := range g.Nodes {
+		data := []graphMLData{{"label", n.Label}}
+		if n.Shape != "" {
+			data = append(data, graphMLData{"shape", string(n.Shape)})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{n.ID, append(data, highlightData(n.Highlight)...)})
+	}
+	for _, e := range g.Edges {
+		data := []graphMLData{}
+		if e.Label != "" {
+			data = append(data, graphMLData{"label", e.Label})
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{e.From, e.To, append(data, highlightData(e.Highlight)...)})
+	}
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(out) + "\n", nil
+}
+
+// Formats are the ways a graph can be exported, only svg has to lay the graph out itself.
+var Formats = map[string]func(g *Graph, layout Layout) (string, error){
+	"dot":     func(g *Graph, _ Layout) (string, error) { return g.DOT(), nil },
+	"mermaid": func(g *Graph, _ Layout) (string, error) { return g.Mermaid(), nil },
+	"graphml": func(g *Graph, _ Layout) (string, error) { return g.GraphML() },
+	"svg":     func(g *Graph, layout Layout) (string, error) { return g.SVG(layout), nil },
+}
+
+func names[V any](m map[string]V) []string {
+	names := []string{}
+	for n := range m {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Request is an export asked for on the command line, a nil request exports nothing.
+type Request struct {
+	Format string
+	// Path is where to write the export, stdout when empty
+	Path string
+	// Layout places the nodes when drawing the graph ourselves
+	Layout string
+}
+
+// exported notes whether any day wrote its graph, so we can tell when one can't.
+var exported bool
+
+func Exported() bool {
+	return exported
+}
+
+// Write exports the graph in the requested format.
+func (r *Request) Write(g *Graph) error {
+	if r == nil {
+		return nil
+	}
+	out, err := Formats[r.Format](g, Layouts[r.Layout])
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if r.Path != "" {
+		f, err := os.Create(r.Path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if _, err := io.WriteString(w, out); err != nil {
+		return err
+	}
+
+	exported = true
+	if r.Path != "" {
+		artifacts.Produced(r.Path)
+	}
+	slog.Info("exported graph", "graph", g.Name, "format", r.Format, "path", r.Path, "nodes", len(g.Nodes), "edges", len(g.Edges))
+	return nil
+}
+
+// AddFlags adds the export flags, they are persistent so every day has them.
+func AddFlags(flags *pflag.FlagSet) {
+	flags.String("export", "", fmt.Sprintf("Export the day's graph as one of %v", names(Formats)))
+	flags.String("export-file", "", "Where to write the export, defaults to stdout")
+	flags.String("layout", "auto", fmt.Sprintf("How to lay out svg exports, one of %v, auto layers directed graphs", names(Layouts)))
+}
+
+// FromFlags reads the export flags, returning nil when no export was asked for.
+func FromFlags(cmd *cobra.Command) (*Request, error) {
+	format, _ := cmd.Flags().GetString("export")
+	if format == "" {
+		return nil, nil
+	}
+	if _, ok := Formats[format]; !ok {
+		return nil, fmt.Errorf("unknown export format %q, pick one of %v", format, names(Formats))
+	}
+	layout, _ := cmd.Flags().GetString("layout")
+	if _, ok := Layouts[layout]; !ok {
+		return nil, fmt.Errorf("unknown layout %q, pick one of %v", layout, names(Layouts))
+	}
+	path, _ := cmd.Flags().GetString("export-file")
+	return &Request{format, path, layout}, nil
+}
@@ -0,0 +1,181 @@
+package graph
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	svg "github.com/ajstarks/svgo"
+)
+
+const (
+	margin      = 40
+	nodeHeight  = 24
+	charWidth   = 7
+	highlighted = "#d00"
+)
+
+// size is how much room a node's shape takes, circles are as wide as they are tall.
+func (n *Node) size() (float64, float64) {
+	w := float64(max(nodeHeight+6, charWidth*len(n.Label)+12))
+	switch n.Shape {
+	case Circle, DoubleCircle:
+		return w, w
+	case Diamond, Hexagon:
+		return w * 1.4, nodeHeight * 1.4
+	}
+	return w, nodeHeight
+}
+
+// clip pulls the end of an edge back from a node's centre to the edge of its box, so arrow
+// heads aren't hidden under the node.
+func (n *Node) clip(from, centre Point) Point {
+	w, h := n.size()
+	dx, dy := from.X-centre.X, from.Y-centre.Y
+	if dx == 0 && dy == 0 {
+		return centre
+	}
+	t := math.Inf(1)
+	if dx != 0 {
+		t = w / 2 / math.Abs(dx)
+	}
+	if dy != 0 {
+		t = min(t, h/2/math.Abs(dy))
+	}
+	if n.Shape == Circle || n.Shape == DoubleCircle {
+		t = w / 2 / math.Hypot(dx, dy)
+	}
+	t = min(t, 1)
+	return Point{centre.X + dx*t, centre.Y + dy*t}
+}
+
+// SVG draws the graph with the given layout, no graphviz or mermaid needed. Highlighted nodes
+// and edges are drawn thick and red.
+func (g *Graph) SVG(layout Layout) string {
+	pos := layout(g)
+
+	// shift everything so the top left node sits inside the margin
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, n := range g.Nodes {
+		p := pos[n.ID]
+		w, h := n.size()
+		minX, minY = min(minX, p.X-w/2), min(minY, p.Y-h/2)
+		maxX, maxY = max(maxX, p.X+w/2), max(maxY, p.Y+h/2)
+	}
+	if len(g.Nodes) == 0 {
+		minX, minY, maxX, maxY = 0, 0, 0, 0
+	}
+	for id, p := range pos {
+		pos[id] = Point{p.X - minX + margin, p.Y - minY + margin}
+	}
+	at := func(p Point) (int, int) {
+		return int(math.Round(p.X)), int(math.Round(p.Y))
+	}
+
+	b := bytes.Buffer{}
+	canvas := svg.New(&b)
+	canvas.Start(int(maxX-minX)+2*margin, int(maxY-minY)+2*margin)
+	canvas.Title(g.Name)
+	if g.Directed {
+		canvas.Def()
+		for _, m := range [][2]string{{"arrow", "#555"}, {"arrow-highlight", highlighted}} {
+			canvas.Marker(m[0], 10, 5, 10, 10, `orient="auto"`, `markerUnits="userSpaceOnUse"`)
+			canvas.Path("M0,0 L10,5 L0,10 z", "fill:"+m[1])
+			canvas.MarkerEnd()
+		}
+		canvas.DefEnd()
+	}
+	canvas.Rect(0, 0, int(maxX-minX)+2*margin, int(maxY-minY)+2*margin, "fill:white")
+
+	// highlighted edges go last so they sit on top of the rest
+	edges := []*Edge{}
+	for _, highlight := range []bool{false, true} {
+		for _, e := range g.Edges {
+			if e.Highlight == highlight {
+				edges = append(edges, e)
+			}
+		}
+	}
+	canvas.Gstyle("font-family:monospace;font-size:11px;text-anchor:middle")
+	for _, e := range edges {
+		style := "stroke:#555;stroke-width:1;fill:none"
+		marker := `marker-end="url(#arrow)"`
+		if e.Highlight {
+			style = fmt.Sprintf("stroke:%s;stroke-width:3;fill:none", highlighted)
+			marker = `marker-end="url(#arrow-highlight)"`
+		}
+		attrs := []string{style}
+		if g.Directed {
+			attrs = append(attrs, marker)
+		}
+
+		from, to := g.nodes[e.From], g.nodes[e.To]
+		a, z := pos[e.From], pos[e.To]
+		if e.From == e.To {
+			// loop out of the top of the node and back in
+			_, h := from.size()
+			x, y := at(Point{a.X, a.Y - h/2})
+			canvas.Path(fmt.Sprintf("M%d,%d c-20,-30 20,-30 %d,0", x-6, y, 12), attrs...)
+			if e.Label != "" {
+				canvas.Text(x, y-24, e.Label)
+			}
+			continue
+		}
+		// long edges bow out to the right of the way they run, so they don't hide behind the
+		// nodes between their ends and edges both ways round don't overlap
+		control := Point{(a.X + z.X) / 2, (a.Y + z.Y) / 2}
+		if d := math.Hypot(z.X-a.X, z.Y-a.Y); d > 1.5*spacing {
+			bow := 0.2
+			control.X -= (z.Y - a.Y) * bow
+			control.Y += (z.X - a.X) * bow
+		}
+		start, end := from.clip(control, a), to.clip(control, z)
+		x1, y1 := at(start)
+		cx, cy := at(control)
+		x2, y2 := at(end)
+		canvas.Path(fmt.Sprintf("M%d,%d Q%d,%d %d,%d", x1, y1, cx, cy, x2, y2), attrs...)
+		if e.Label != "" {
+			x, y := at(Point{
+				(start.X + 2*control.X + end.X) / 4,
+				(start.Y + 2*control.Y + end.Y) / 4,
+			})
+			canvas.Text(x, y-3, e.Label, "fill:#333")
+		}
+	}
+
+	for _, n := range g.Nodes {
+		style := "fill:#f4f4f4;stroke:#333;stroke-width:1"
+		if n.Highlight {
+			style = fmt.Sprintf("fill:#fde0e0;stroke:%s;stroke-width:3", highlighted)
+		}
+		w, h := n.size()
+		x, y := at(pos[n.ID])
+		hw, hh := int(w/2), int(h/2)
+		switch n.Shape {
+		case Box:
+			canvas.Rect(x-hw, y-hh, 2*hw, 2*hh, style)
+		case Rounded:
+			canvas.Roundrect(x-hw, y-hh, 2*hw, 2*hh, hh, hh, style)
+		case Circle:
+			canvas.Circle(x, y, hw, style)
+		case DoubleCircle:
+			canvas.Circle(x, y, hw, style)
+			canvas.Circle(x, y, hw-4, style)
+		case Diamond:
+			canvas.Polygon([]int{x, x + hw, x, x - hw}, []int{y - hh, y, y + hh, y}, style)
+		case Hexagon:
+			q := hw / 2
+			canvas.Polygon(
+				[]int{x - hw, x - q, x + q, x + hw, x + q, x - q},
+				[]int{y, y - hh, y - hh, y, y + hh, y + hh},
+				style,
+			)
+		default:
+			canvas.Ellipse(x, y, hw, hh, style)
+		}
+		canvas.Text(x, y+4, n.Label)
+	}
+	canvas.Gend()
+	canvas.End()
+	return b.String()
+}
@@ -0,0 +1,175 @@
+package render
+
+import (
+	"adventofcode/cmd/artifacts"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"log/slog"
+	"os"
+
+	"git.sr.ht/~sbinet/gg"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Points marks single cells with a dot, like beams or a search frontier.
+type Points Visited
+
+func (p Points) Draw(dc *gg.Context, o Options) {
+	size := float64(o.CellSize)
+	dc.SetColor(o.Palette.Path)
+	for _, c := range p {
+		dc.DrawCircle((float64(c.Col)+0.5)*size, (float64(c.Row)+0.5)*size, max(1, size/3))
+	}
+	dc.Fill()
+}
+
+// Recorder collects frames of a simulation as it runs and writes them out as a GIF. Loops
+// call Frame every step, a nil recorder ignores them so they don't need to check.
+type Recorder struct {
+	Path string
+	Options
+	// Every keeps one step in this many
+	Every int
+	// MaxFrames caps the animation, once reached every other frame is dropped and Every doubles
+	MaxFrames int
+	// MaxSize is the most pixels either side of a frame may have, the cell size shrinks to fit
+	MaxSize int
+	// Delay between frames in hundredths of a second
+	Delay int
+
+	steps  int
+	frames []*image.Paletted
+	// skipped holds the latest step that was not kept, so the animation always ends on the last one
+	skipped func() *image.Paletted
+}
+
+// recorded notes whether any day recorded an animation, so we can tell when one can't.
+var recorded bool
+
+func Recorded() bool {
+	return recorded
+}
+
+// Frame records a step of the simulation.
+func (r *Recorder) Frame(rows []string, overlays ...Overlay) {
+	if r == nil {
+		return
+	}
+	step := r.steps
+	r.steps++
+
+	// rows are often reused by the caller, hold onto a copy until the frame is drawn
+	rows = append([]string{}, rows...)
+	draw := func() *image.Paletted {
+		return r.paletted(Render(rows, r.fit(rows), overlays...))
+	}
+	if step%r.Every != 0 {
+		r.skipped = draw
+		return
+	}
+	r.skipped = nil
+
+	r.frames = append(r.frames, draw())
+	if len(r.frames) > r.MaxFrames {
+		kept := r.frames[:0]
+		for i, f := range r.frames {
+			if i%2 == 0 {
+				kept = append(kept, f)
+			}
+		}
+		r.frames = kept
+		r.Every *= 2
+		slog.Debug("too many frames, skipping more", "every", r.Every)
+	}
+}
+
+// fit shrinks the cell size until a frame of these rows is within MaxSize.
+func (r *Recorder) fit(rows []string) Options {
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	o := r.Options
+	longest := max(width, len(rows), 1)
+	if o.CellSize*longest > r.MaxSize {
+		o.CellSize = max(1, r.MaxSize/longest)
+	}
+	return o
+}
+
+func (r *Recorder) paletted(img image.Image) *image.Paletted {
+	p := image.NewPaletted(img.Bounds(), palette.Plan9)
+	draw.Draw(p, p.Rect, img, img.Bounds().Min, draw.Src)
+	return p
+}
+
+// Save writes the recorded frames, doing nothing for a nil recorder.
+func (r *Recorder) Save() error {
+	if r == nil {
+		return nil
+	}
+	if r.skipped != nil {
+		r.frames = append(r.frames, r.skipped())
+	}
+	if len(r.frames) == 0 {
+		return fmt.Errorf("no frames were recorded for %s", r.Path)
+	}
+
+	animation := &gif.GIF{}
+	for _, f := range r.frames {
+		animation.Image = append(animation.Image, f)
+		animation.Delay = append(animation.Delay, r.Delay)
+	}
+	// hold the final frame so the result is easy to see
+	animation.Delay[len(animation.Delay)-1] = max(r.Delay, 100)
+
+	f, err := os.Create(r.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := gif.EncodeAll(f, animation); err != nil {
+		return err
+	}
+
+	recorded = true
+	artifacts.Produced(r.Path)
+	slog.Info("animated", "path", r.Path, "steps", r.steps, "frames", len(r.frames))
+	return nil
+}
+
+// AddAnimationFlags adds the animation flags, they share the cell size and palette with rendering.
+func AddAnimationFlags(flags *pflag.FlagSet) {
+	flags.String("animate", "", "Record the day's simulation as a GIF to this path")
+	flags.Int("frame-every", 1, "Keep one simulation step in this many when animating")
+	flags.Int("max-frames", 500, "Most frames to animate, later steps thin out the earlier ones")
+	flags.Int("max-frame-size", 1024, "Most pixels along either side of an animation frame")
+	flags.Int("frame-delay", 5, "Hundredths of a second between animation frames")
+}
+
+// RecorderFromFlags reads the animation flags, returning nil when no animation was asked for.
+func RecorderFromFlags(cmd *cobra.Command) (*Recorder, error) {
+	path, _ := cmd.Flags().GetString("animate")
+	if path == "" {
+		return nil, nil
+	}
+	o, err := optionsFromFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{Path: path, Options: o}
+	r.Every, _ = cmd.Flags().GetInt("frame-every")
+	r.MaxFrames, _ = cmd.Flags().GetInt("max-frames")
+	r.MaxSize, _ = cmd.Flags().GetInt("max-frame-size")
+	r.Delay, _ = cmd.Flags().GetInt("frame-delay")
+	if r.Every < 1 || r.MaxFrames < 1 || r.MaxSize < 1 || r.Delay < 0 {
+		return nil, fmt.Errorf("frame every %d, max frames %d and max frame size %d must be positive, delay %d can't be negative",
+			r.Every, r.MaxFrames, r.MaxSize, r.Delay)
+	}
+	return r, nil
+}
@@ -0,0 +1,271 @@
+package render
+
+import (
+	"adventofcode/cmd/artifacts"
+	"adventofcode/cmd/coordinates"
+	"fmt"
+	"image"
+	"image/color"
+	"log/slog"
+	"slices"
+
+	"git.sr.ht/~sbinet/gg"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Palette picks the colours for a rendered grid.
+type Palette struct {
+	Background color.Color
+	// Glyphs colour specific cells of the grid, any other glyph is drawn with Default
+	Glyphs  map[rune]color.Color
+	Default color.Color
+	Path    color.Color
+	Visited color.Color
+	// Heat runs from the lowest value of a heatmap to the highest
+	Heat []color.Color
+}
+
+var Palettes = map[string]*Palette{
+	"dark": {
+		Background: color.RGBA{0x0f, 0x0f, 0x23, 0xff},
+		Glyphs: map[rune]color.Color{
+			'.': color.RGBA{0x0f, 0x0f, 0x23, 0xff},
+			'#': color.RGBA{0x5a, 0x5a, 0x6e, 0xff},
+		},
+		Default: color.RGBA{0xcc, 0xcc, 0xcc, 0xff},
+		Path:    color.RGBA{0xff, 0xff, 0x66, 0xff},
+		Visited: color.RGBA{0x00, 0x99, 0x00, 0xaa},
+		Heat: []color.Color{
+			color.RGBA{0x00, 0x00, 0x99, 0xcc},
+			color.RGBA{0x00, 0xcc, 0x00, 0xcc},
+			color.RGBA{0xff, 0x00, 0x00, 0xcc},
+		},
+	},
+	"light": {
+		Background: color.White,
+		Glyphs: map[rune]color.Color{
+			'.': color.White,
+			'#': color.RGBA{0x44, 0x44, 0x44, 0xff},
+		},
+		Default: color.RGBA{0x99, 0x99, 0x99, 0xff},
+		Path:    color.RGBA{0xcc, 0x00, 0x00, 0xff},
+		Visited: color.RGBA{0x33, 0x66, 0xcc, 0x88},
+		Heat: []color.Color{
+			color.RGBA{0xff, 0xff, 0xcc, 0xcc},
+			color.RGBA{0xfd, 0x8d, 0x3c, 0xcc},
+			color.RGBA{0x80, 0x00, 0x26, 0xcc},
+		},
+	},
+}
+
+func (p *Palette) glyph(r rune) color.Color {
+	if c, ok := p.Glyphs[r]; ok {
+		return c
+	}
+	return p.Default
+}
+
+// heat blends between the heat colours, f running from 0 to 1.
+func (p *Palette) heat(f float64) color.Color {
+	if len(p.Heat) == 1 || f <= 0 {
+		return p.Heat[0]
+	}
+	if f >= 1 {
+		return p.Heat[len(p.Heat)-1]
+	}
+	scaled := f * float64(len(p.Heat)-1)
+	i := int(scaled)
+	return blend(p.Heat[i], p.Heat[i+1], scaled-float64(i))
+}
+
+func blend(a, b color.Color, f float64) color.Color {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	mix := func(x, y uint32) uint8 {
+		return uint8((float64(x)*(1-f) + float64(y)*f) / 0x101)
+	}
+	return color.RGBA{mix(ar, br), mix(ag, bg), mix(ab, bb), mix(aa, ba)}
+}
+
+// Options control how big each cell is and how it is coloured.
+type Options struct {
+	CellSize int
+	Palette  *Palette
+}
+
+// Overlay is drawn on top of the grid, in the order given.
+type Overlay interface {
+	Draw(dc *gg.Context, o Options)
+}
+
+func cellRect(dc *gg.Context, c coordinates.Coordinate, size float64) {
+	dc.DrawRectangle(float64(c.Col)*size, float64(c.Row)*size, size, size)
+}
+
+// Path is a line through the centre of each cell in turn.
+type Path []coordinates.Coordinate
+
+func (p Path) Draw(dc *gg.Context, o Options) {
+	if len(p) == 0 {
+		return
+	}
+	size := float64(o.CellSize)
+	centre := func(c coordinates.Coordinate) (float64, float64) {
+		return (float64(c.Col) + 0.5) * size, (float64(c.Row) + 0.5) * size
+	}
+
+	dc.SetColor(o.Palette.Path)
+	dc.SetLineWidth(max(1, size/3))
+	dc.MoveTo(centre(p[0]))
+	for _, c := range p[1:] {
+		dc.LineTo(centre(c))
+	}
+	dc.Stroke()
+
+	// mark where it starts and ends
+	for _, c := range []coordinates.Coordinate{p[0], p[len(p)-1]} {
+		x, y := centre(c)
+		dc.DrawCircle(x, y, size/2)
+		dc.Fill()
+	}
+}
+
+// Visited shades every cell in the set.
+type Visited []coordinates.Coordinate
+
+func (v Visited) Draw(dc *gg.Context, o Options) {
+	dc.SetColor(o.Palette.Visited)
+	for _, c := range v {
+		cellRect(dc, c, float64(o.CellSize))
+	}
+	dc.Fill()
+}
+
+// Heatmap colours each cell by its value, cells holding Unset are left alone.
+type Heatmap struct {
+	Values [][]int
+	Unset  int
+}
+
+func (h Heatmap) Draw(dc *gg.Context, o Options) {
+	low, high := 0, 0
+	first := true
+	for _, row := range h.Values {
+		for _, v := range row {
+			if v == h.Unset {
+				continue
+			}
+			if first || v < low {
+				low = v
+			}
+			if first || v > high {
+				high = v
+			}
+			first = false
+		}
+	}
+	spread := float64(max(high-low, 1))
+
+	for r, row := range h.Values {
+		for c, v := range row {
+			if v == h.Unset {
+				continue
+			}
+			dc.SetColor(o.Palette.heat(float64(v-low) / spread))
+			cellRect(dc, coordinates.Coordinate{Row: r, Col: c}, float64(o.CellSize))
+			dc.Fill()
+		}
+	}
+}
+
+// Render draws the grid with one square per character then the overlays on top.
+func Render(rows []string, o Options, overlays ...Overlay) image.Image {
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	size := float64(o.CellSize)
+	dc := gg.NewContext(width*o.CellSize, len(rows)*o.CellSize)
+	dc.SetColor(o.Palette.Background)
+	dc.Clear()
+
+	for r, row := range rows {
+		for c, glyph := range row {
+			dc.SetColor(o.Palette.glyph(glyph))
+			cellRect(dc, coordinates.Coordinate{Row: r, Col: c}, size)
+			dc.Fill()
+		}
+	}
+
+	for _, overlay := range overlays {
+		overlay.Draw(dc, o)
+	}
+	return dc.Image()
+}
+
+// Request is a rendering asked for on the command line, a nil request renders nothing.
+type Request struct {
+	Path string
+	Options
+}
+
+// rendered notes whether any day wrote its rendering, so we can tell when one can't.
+var rendered bool
+
+func Rendered() bool {
+	return rendered
+}
+
+// Write renders the grid to the requested PNG.
+func (r *Request) Write(rows []string, overlays ...Overlay) error {
+	if r == nil {
+		return nil
+	}
+	img := Render(rows, r.Options, overlays...)
+	if err := gg.SavePNG(r.Path, img); err != nil {
+		return err
+	}
+	rendered = true
+	artifacts.Produced(r.Path)
+	slog.Info("rendered", "path", r.Path, "width", img.Bounds().Dx(), "height", img.Bounds().Dy())
+	return nil
+}
+
+// AddFlags adds the rendering flags, they are persistent so every day has them.
+func AddFlags(flags *pflag.FlagSet) {
+	flags.String("render", "", "Render the day's grid as a PNG to this path")
+	flags.Int("cell-size", 8, "Pixels per grid cell when rendering")
+	flags.String("palette", "dark", "Colours to render with, dark or light")
+}
+
+// FromFlags reads the rendering flags, returning nil when no rendering was asked for.
+func FromFlags(cmd *cobra.Command) (*Request, error) {
+	path, _ := cmd.Flags().GetString("render")
+	if path == "" {
+		return nil, nil
+	}
+	o, err := optionsFromFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return &Request{path, o}, nil
+}
+
+func optionsFromFlags(cmd *cobra.Command) (Options, error) {
+	cellSize, _ := cmd.Flags().GetInt("cell-size")
+	if cellSize < 1 {
+		return Options{}, fmt.Errorf("cell size must be at least 1, got %d", cellSize)
+	}
+	name, _ := cmd.Flags().GetString("palette")
+	palette, ok := Palettes[name]
+	if !ok {
+		names := []string{}
+		for n := range Palettes {
+			names = append(names, n)
+		}
+		slices.Sort(names)
+		return Options{}, fmt.Errorf("unknown palette %q, pick one of %v", name, names)
+	}
+	return Options{cellSize, palette}, nil
+}
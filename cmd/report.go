@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"adventofcode/cmd/runs"
+	"log"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var ReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Chart the recorded run timings and series",
+	Run: func(cmd *cobra.Command, args []string) {
+		timings, _ := cmd.Flags().GetString("timings")
+		if timings == "" {
+			log.Fatal("--timings must point at the file runs were recorded to")
+		}
+		out, _ := cmd.Flags().GetString("out")
+		format, _ := cmd.Flags().GetString("format")
+		if format != "png" && format != "svg" {
+			log.Fatalf("unknown chart format %q, pick png or svg", format)
+		}
+
+		recorded, err := runs.Load(timings)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(recorded) == 0 {
+			log.Fatalf("no runs were recorded in %s", timings)
+		}
+		if err := os.MkdirAll(out, 0755); err != nil {
+			log.Fatal(err)
+		}
+
+		charts := []*runs.Chart{}
+		for _, chart := range []func([]*runs.Run) (*runs.Chart, error){runs.TimePerDay, runs.Trend} {
+			c, err := chart(recorded)
+			if err != nil {
+				log.Fatal(err)
+			}
+			charts = append(charts, c)
+		}
+		series, err := runs.SeriesCharts(recorded)
+		if err != nil {
+			log.Fatal(err)
+		}
+		charts = append(charts, series...)
+
+		for _, c := range charts {
+			path, err := c.Save(out, format)
+			if err != nil {
+				log.Fatal(err)
+			}
+			slog.Info("charted", "path", path)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(ReportCmd)
+	ReportCmd.Flags().String("out", "report", "Directory to write the charts to")
+	ReportCmd.Flags().String("format", "png", "Chart format, png or svg")
+}
@@ -1,6 +1,10 @@
 package cmd
 
 import (
+	"adventofcode/cmd/artifacts"
+	"adventofcode/cmd/graph"
+	"adventofcode/cmd/render"
+	"adventofcode/cmd/runs"
 	"fmt"
 	"log/slog"
 	"os"
@@ -16,6 +20,34 @@ var puzzleInput string
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use: "adventofcode",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		artifacts.Start(cmd)
+		if path, _ := cmd.Flags().GetString("timings"); path != "" && strings.HasPrefix(cmd.Name(), "day") {
+			runs.Start(cmd)
+		}
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		for _, path := range artifacts.Finish() {
+			slog.Info("produced", "path", path)
+		}
+		if path, _ := cmd.Flags().GetString("timings"); path != "" && strings.HasPrefix(cmd.Name(), "day") {
+			run, err := runs.Finish(path)
+			if err != nil {
+				slog.Error("failed to record the run", "path", path, "err", err)
+			} else {
+				slog.Debug("recorded run", "run", run, "path", path)
+			}
+		}
+		if path, _ := cmd.Flags().GetString("render"); path != "" && !render.Rendered() {
+			slog.Warn("nothing was rendered, this day has no grid to draw", "day", cmd.Name())
+		}
+		if path, _ := cmd.Flags().GetString("animate"); path != "" && !render.Recorded() {
+			slog.Warn("nothing was animated, this day has no simulation to record", "day", cmd.Name())
+		}
+		if format, _ := cmd.Flags().GetString("export"); format != "" && !graph.Exported() {
+			slog.Warn("nothing was exported, this day has no graph to export", "day", cmd.Name())
+		}
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -39,6 +71,11 @@ func init() {
 	rootCmd.MarkFlagRequired("puzzle-input")
 
 	rootCmd.PersistentFlags().Bool("part-two", false, "Whether to run part two of the day's challenge")
+	render.AddFlags(rootCmd.PersistentFlags())
+	render.AddAnimationFlags(rootCmd.PersistentFlags())
+	graph.AddFlags(rootCmd.PersistentFlags())
+	runs.AddFlags(rootCmd.PersistentFlags())
+	artifacts.AddFlags(rootCmd.PersistentFlags())
 
 	// Logging configuration
 	var logLevel slog.Level
@@ -0,0 +1,184 @@
+package runs
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+)
+
+// Chart is a plot and the name of the file it is saved to.
+type Chart struct {
+	Name string
+	Plot *plot.Plot
+}
+
+// Save writes the chart into dir, the format picked by ext like png or svg.
+func (c *Chart) Save(dir, ext string) (string, error) {
+	path := filepath.Join(dir, c.Name+"."+ext)
+	return path, c.Plot.Save(10*vg.Inch, 6*vg.Inch, path)
+}
+
+// key groups runs of the same day and part.
+func (r *Run) key() string {
+	return fmt.Sprintf("%s part %d", r.Day, r.Part)
+}
+
+func median(values []float64) float64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// commits lists the commits in the order they were first run.
+func commits(rs []*Run) []string {
+	order := []string{}
+	for _, r := range rs {
+		if !slices.Contains(order, r.Commit) {
+			order = append(order, r.Commit)
+		}
+	}
+	return order
+}
+
+// keys lists each day and part once, sorted.
+func keys(rs []*Run) []string {
+	ks := []string{}
+	for _, r := range rs {
+		if !slices.Contains(ks, r.key()) {
+			ks = append(ks, r.key())
+		}
+	}
+	slices.Sort(ks)
+	return ks
+}
+
+// TimePerDay is a bar for each day and part, the median time of its runs on the latest
+// commit it was run on.
+func TimePerDay(rs []*Run) (*Chart, error) {
+	latest := map[string]string{}
+	for _, r := range rs {
+		latest[r.key()] = r.Commit
+	}
+	ks := keys(rs)
+	times := plotter.Values{}
+	for _, k := range ks {
+		millis := []float64{}
+		for _, r := range rs {
+			if r.key() == k && r.Commit == latest[k] {
+				millis = append(millis, r.Millis)
+			}
+		}
+		times = append(times, median(millis))
+	}
+
+	p := plot.New()
+	p.Title.Text = "Time per day and part"
+	p.Y.Label.Text = "milliseconds"
+	bars, err := plotter.NewBarChart(times, vg.Points(12))
+	if err != nil {
+		return nil, err
+	}
+	bars.Color = plotutil.Color(0)
+	p.Add(bars)
+	p.NominalX(ks...)
+	p.X.Tick.Label.Rotation = 0.8
+	p.X.Tick.Label.XAlign = -0.9
+	return &Chart{"time-per-day", p}, nil
+}
+
+// Trend is a line for each day and part through the median time of its runs on each commit,
+// commits running left to right in the order they were first run.
+func Trend(rs []*Run) (*Chart, error) {
+	order := commits(rs)
+	index := map[string]int{}
+	for i, c := range order {
+		index[c] = i
+	}
+
+	p := plot.New()
+	p.Title.Text = "Time across commits"
+	p.Y.Label.Text = "milliseconds"
+	p.X.Label.Text = "commit"
+	lines := []any{}
+	for _, k := range keys(rs) {
+		byCommit := map[string][]float64{}
+		for _, r := range rs {
+			if r.key() == k {
+				byCommit[r.Commit] = append(byCommit[r.Commit], r.Millis)
+			}
+		}
+		points := plotter.XYs{}
+		for _, c := range order {
+			if millis, ok := byCommit[c]; ok {
+				points = append(points, plotter.XY{X: float64(index[c]), Y: median(millis)})
+			}
+		}
+		lines = append(lines, k, points)
+	}
+	if err := plotutil.AddLinePoints(p, lines...); err != nil {
+		return nil, err
+	}
+
+	ticks := []plot.Tick{}
+	for i, c := range order {
+		ticks = append(ticks, plot.Tick{Value: float64(i), Label: c})
+	}
+	p.X.Tick.Marker = plot.ConstantTicks(ticks)
+	p.X.Tick.Label.Rotation = 0.8
+	p.X.Tick.Label.XAlign = -0.9
+	p.Legend.Top = true
+	return &Chart{"trend", p}, nil
+}
+
+// SeriesCharts draws what each day observed on its latest run of each input, like the
+// dayFourteen load per cycle.
+func SeriesCharts(rs []*Run) ([]*Chart, error) {
+	latest := map[string]*Run{}
+	order := []string{}
+	for _, r := range rs {
+		if len(r.Series) == 0 {
+			continue
+		}
+		k := r.key() + " " + r.Input
+		if _, ok := latest[k]; !ok {
+			order = append(order, k)
+		}
+		latest[k] = r
+	}
+
+	charts := []*Chart{}
+	for _, k := range order {
+		r := latest[k]
+		names := []string{}
+		for name := range r.Series {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		for _, name := range names {
+			points := plotter.XYs{}
+			for _, pt := range r.Series[name] {
+				points = append(points, plotter.XY{X: pt.X, Y: pt.Y})
+			}
+			p := plot.New()
+			p.Title.Text = fmt.Sprintf("%s part %d %s (%s)", r.Day, r.Part, name, filepath.Base(r.Input))
+			p.Y.Label.Text = name
+			if err := plotutil.AddLinePoints(p, points); err != nil {
+				return nil, err
+			}
+			file := fmt.Sprintf("%s-part%d-%s-%s", r.Day, r.Part, strings.TrimSuffix(filepath.Base(r.Input), filepath.Ext(r.Input)), name)
+			charts = append(charts, &Chart{strings.ReplaceAll(file, " ", "-"), p})
+		}
+	}
+	return charts, nil
+}
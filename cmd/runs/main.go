@@ -0,0 +1,127 @@
+package runs
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+type Point struct {
+	X, Y float64
+}
+
+// Run is one timed run of a day, written as a line of JSON to the timings file.
+type Run struct {
+	Day   string
+	Part  int
+	Input string
+	// Commit is the short hash the run was built from, with -dirty when there were changes
+	Commit  string
+	Started time.Time
+	Millis  float64
+	// Series are whatever the day observed along the way, like the load after each cycle
+	Series map[string][]Point `json:",omitempty"`
+}
+
+func (r *Run) String() string {
+	return fmt.Sprintf("%s part %d on %s at %s: %.2fms", r.Day, r.Part, r.Input, r.Commit, r.Millis)
+}
+
+// current is the run in progress, days observe into it without having to pass it around.
+var current *Run
+
+// Start begins timing a day's command.
+func Start(cmd *cobra.Command) {
+	current = &Run{
+		Day:     cmd.Name(),
+		Part:    1,
+		Commit:  commit(),
+		Started: time.Now(),
+		Series:  map[string][]Point{},
+	}
+	if cmd.Flag("part-two").Changed {
+		current.Part = 2
+	}
+	current.Input, _ = cmd.Flags().GetString("puzzle-input")
+}
+
+// Observe adds a point to one of the current run's series, it does nothing outside a run.
+func Observe(series string, x, y float64) {
+	if current == nil {
+		return
+	}
+	current.Series[series] = append(current.Series[series], Point{x, y})
+}
+
+// Finish stops the clock and appends the run to the timings file.
+func Finish(path string) (*Run, error) {
+	if current == nil {
+		return nil, errors.New("no run was started")
+	}
+	r := current
+	current = nil
+	r.Millis = float64(time.Since(r.Started).Microseconds()) / 1000.0
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	line, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.Write(append(line, '\n'))
+	return r, err
+}
+
+// Load reads every run from the timings file, oldest first.
+func Load(path string) ([]*Run, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	loaded := []*Run{}
+	scanner := bufio.NewScanner(f)
+	// series can make for long lines
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		r := &Run{}
+		if err := json.Unmarshal(scanner.Bytes(), r); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		loaded = append(loaded, r)
+	}
+	return loaded, scanner.Err()
+}
+
+// commit asks git what we're running, runs outside a checkout are just unknown.
+func commit() string {
+	hash, err := exec.Command("git", "rev-parse", "--short", "HEAD").Output()
+	if err != nil {
+		return "unknown"
+	}
+	c := strings.TrimSpace(string(hash))
+	if status, err := exec.Command("git", "status", "--porcelain", "--untracked-files=no").Output(); err == nil && len(status) > 0 {
+		c += "-dirty"
+	}
+	return c
+}
+
+// AddFlags adds the timings flag, it is persistent so every day has it.
+func AddFlags(flags *pflag.FlagSet) {
+	flags.String("timings", "", "Append how long the run took, and any series the day observed, to this file")
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"math"
 	"os"
 	"strings"
 )
@@ -37,3 +38,23 @@ func InDebugMode() bool {
 	level := strings.ToLower(os.Getenv("LOG_LEVEL"))
 	return level == "debug"
 }
+
+// CheckedAdd adds two int64s, reporting false if the sum overflows.
+func CheckedAdd(a, b int64) (int64, bool) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, false
+	}
+	return a + b, true
+}
+
+// CheckedMul multiplies two int64s, reporting false if the product overflows.
+func CheckedMul(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return product, true
+}